@@ -1,7 +1,9 @@
 package logfjournald
 
 import (
-	"github.com/ssgreg/journald"
+	"io"
+	"net"
+
 	"github.com/ssgreg/logf"
 )
 
@@ -12,10 +14,31 @@ type AppenderCloseFunc func() error
 // NewAppender creates the new instance of journal appender with the given
 // Encoder.
 func NewAppender(enc logf.Encoder) (logf.Appender, AppenderCloseFunc) {
+	return NewAppenderWithConfig(AppenderConfig{Encoder: enc})
+}
+
+// NewAppenderWithConfig creates the new instance of journal appender with
+// the given AppenderConfig.
+func NewAppenderWithConfig(c AppenderConfig) (logf.Appender, AppenderCloseFunc) {
+	c = c.WithDefaults()
 	a := &appender{
-		j:   &journald.Journal{},
-		enc: enc,
-		buf: logf.NewBufferWithCapacity(logf.PageSize * 2),
+		enc:            c.Encoder,
+		buf:            logf.NewBufferWithCapacity(c.BufferCapacity),
+		flushThreshold: c.FlushThreshold,
+		namespace:      c.Namespace,
+		socketPath:     c.SocketPath,
+	}
+	if c.Fallback {
+		a.fallback = c.FallbackWriter
+		a.fallbackBuf = logf.NewBuffer()
+	}
+	if c.SocketPath == "" {
+		a.j = &brokenJournal{ValidateNamespace(c.Namespace)}
+	} else {
+		a.j = &socketJournal{
+			addr:        &net.UnixAddr{Name: c.SocketPath, Net: "unixgram"},
+			fdThreshold: c.MemfdThreshold,
+		}
 	}
 
 	return a, AppenderCloseFunc(func() error {
@@ -24,9 +47,24 @@ func NewAppender(enc logf.Encoder) (logf.Appender, AppenderCloseFunc) {
 }
 
 type appender struct {
-	j   *journald.Journal
-	enc logf.Encoder
-	buf *logf.Buffer
+	j              journal
+	enc            logf.Encoder
+	buf            *logf.Buffer
+	flushThreshold int
+
+	// namespace and socketPath are used for error reporting only.
+	namespace  string
+	socketPath string
+
+	// fallback receives entries in case the journal is unavailable.
+	fallback    io.Writer
+	fallbackBuf *logf.Buffer
+
+	// ends holds the end offsets of encoded entries in buf. The journal
+	// native protocol expects exactly one entry per datagram, so entries
+	// are batched in buf but written as separate datagrams.
+	ends []int
+	msgs [][]byte
 }
 
 func (a *appender) Append(entry logf.Entry) error {
@@ -34,7 +72,8 @@ func (a *appender) Append(entry logf.Entry) error {
 	if err != nil {
 		return err
 	}
-	if a.buf.Len() > logf.PageSize {
+	a.ends = append(a.ends, a.buf.Len())
+	if a.buf.Len() > a.flushThreshold {
 		a.Flush()
 	}
 
@@ -46,10 +85,34 @@ func (a *appender) Sync() (err error) {
 }
 
 func (a *appender) Flush() error {
-	if a.buf.Len() != 0 {
-		defer a.buf.Reset()
+	if len(a.ends) == 0 {
+		return nil
+	}
+	defer a.reset()
 
-		return a.j.WriteMsg(a.buf.Bytes())
+	start := 0
+	for _, end := range a.ends {
+		a.msgs = append(a.msgs, a.entry(start, end))
+		start = end
+	}
+
+	// Try to write all entries even if some of them fail.
+	var errs []EntryError
+	if bj, ok := a.j.(batchJournal); ok {
+		errs = bj.WriteMsgs(a.msgs)
+	} else {
+		errs = writeMsgs(a.j, a.msgs)
+	}
+	if len(errs) != 0 && a.fallback != nil {
+		errs = a.writeFallback(errs)
+	}
+	if len(errs) != 0 {
+		return &FlushError{
+			Namespace:  a.namespace,
+			SocketPath: a.socketPath,
+			Total:      len(a.msgs),
+			Entries:    errs,
+		}
 	}
 
 	return nil
@@ -62,3 +125,48 @@ func (a *appender) Close() error {
 
 	return a.j.Close()
 }
+
+// writeFallback writes entries that failed because of journal
+// unavailability to the fallback writer. It returns errors for entries
+// that were not written.
+func (a *appender) writeFallback(errs []EntryError) []EntryError {
+	defer a.fallbackBuf.Reset()
+
+	var rest []EntryError
+	for _, e := range errs {
+		if !isJournalUnavailable(e.Err) {
+			rest = append(rest, e)
+
+			continue
+		}
+		if err := appendStreamLine(a.fallbackBuf, a.msgs[e.Index]); err != nil {
+			rest = append(rest, EntryError{Index: e.Index, Err: err})
+		}
+	}
+	if a.fallbackBuf.Len() == 0 {
+		return rest
+	}
+
+	if _, err := a.fallback.Write(a.fallbackBuf.Bytes()); err != nil {
+		// Nothing was written. Report the original errors.
+		return errs
+	}
+
+	return rest
+}
+
+// entry returns the encoded entry located in buf between start and end.
+// Encoder separates entries with a newline, it is not a part of an entry.
+func (a *appender) entry(start, end int) []byte {
+	if start != 0 && a.buf.Data[start] == '\n' {
+		start++
+	}
+
+	return a.buf.Data[start:end]
+}
+
+func (a *appender) reset() {
+	a.buf.Reset()
+	a.ends = a.ends[:0]
+	a.msgs = a.msgs[:0]
+}
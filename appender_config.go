@@ -0,0 +1,85 @@
+package logfjournald
+
+import (
+	"io"
+	"os"
+
+	"github.com/ssgreg/logf"
+)
+
+// Default appender settings.
+const (
+	// DefaultSocketPath is the path of the system journal's native
+	// protocol socket.
+	DefaultSocketPath = "/run/systemd/journal/socket"
+
+	DefaultFlushThreshold = logf.PageSize
+	DefaultBufferCapacity = logf.PageSize * 2
+)
+
+// AppenderConfig allows to configure journal Appender.
+type AppenderConfig struct {
+	// SocketPath is the path of the journal's native protocol socket.
+	// Takes precedence over Namespace if both are specified.
+	SocketPath string
+
+	// Namespace is the name of the journal namespace to write entries
+	// to (see LogNamespace= in systemd.exec(5)). The default namespace
+	// is used if empty.
+	Namespace string
+
+	// FlushThreshold is the size of encoded entries in bytes that makes
+	// the Appender to flush them automatically.
+	FlushThreshold int
+
+	// BufferCapacity is the initial capacity of the Appender's buffer.
+	BufferCapacity int
+
+	// MemfdThreshold is the size of an encoded entry in bytes that makes
+	// the Appender to pass the entry to the journal via sealed memfd
+	// instead of a datagram. Zero means that memfd is used only for
+	// entries that do not fit in a datagram.
+	MemfdThreshold int
+
+	// Encoder encodes entries. It must produce the journal native
+	// protocol format.
+	Encoder logf.Encoder
+
+	// Fallback enables writing entries to FallbackWriter if the journal
+	// socket does not exist or nobody listens on it. Entries are written
+	// as lines with sd-daemon(3) priority prefixes:
+	//
+	//	<6>got cpu info LEVEL=info COUNT=4
+	//
+	// journald understands the prefixes if the process's output is
+	// connected to it.
+	Fallback bool
+
+	// FallbackWriter is the destination for entries if Fallback is
+	// enabled. Default is os.Stderr.
+	FallbackWriter io.Writer
+}
+
+// WithDefaults returns the new config in which all uninitialized fields are
+// filled with their default values.
+func (c AppenderConfig) WithDefaults() AppenderConfig {
+	if c.SocketPath == "" {
+		// Invalid namespace leaves SocketPath empty. The Appender
+		// reports the error on each write.
+		c.SocketPath, _ = NamespaceSocketPath(c.Namespace)
+	}
+	if c.FlushThreshold == 0 {
+		c.FlushThreshold = DefaultFlushThreshold
+	}
+	if c.BufferCapacity == 0 {
+		c.BufferCapacity = DefaultBufferCapacity
+	}
+	if c.Encoder == nil {
+		c.Encoder = NewEncoder.Default()
+	}
+	if c.FallbackWriter == nil {
+		c.FallbackWriter = os.Stderr
+	}
+
+	return c
+}
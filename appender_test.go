@@ -1,6 +1,8 @@
 package logfjournald
 
 import (
+	"errors"
+	"net"
 	"testing"
 
 	"github.com/ssgreg/logf"
@@ -8,11 +10,12 @@ import (
 )
 
 func TestAppender(t *testing.T) {
+	c := listenTestJournal(t)
 	baseApp, close := NewAppender(NewEncoder.Default())
 
 	app, ok := baseApp.(*appender)
 	require.True(t, ok)
-	app.j.TestModeEnabled = true
+	app.j.(*socketJournal).addr = c.LocalAddr().(*net.UnixAddr)
 	require.Empty(t, app.buf.Len())
 
 	t.Run("AppendNotFlush", func(t *testing.T) {
@@ -42,3 +45,202 @@ func TestAppender(t *testing.T) {
 		require.Empty(t, app.j)
 	})
 }
+
+func TestAppenderWithConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		c := AppenderConfig{}.WithDefaults()
+		require.Equal(t, DefaultSocketPath, c.SocketPath)
+		require.Equal(t, DefaultFlushThreshold, c.FlushThreshold)
+		require.Equal(t, DefaultBufferCapacity, c.BufferCapacity)
+		require.NotNil(t, c.Encoder)
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		c := AppenderConfig{Namespace: "tenant"}.WithDefaults()
+		require.Equal(t, "/run/systemd/journal.tenant/socket", c.SocketPath)
+
+		c = AppenderConfig{Namespace: "tenant", SocketPath: "/s"}.WithDefaults()
+		require.Equal(t, "/s", c.SocketPath)
+	})
+
+	t.Run("SocketPath", func(t *testing.T) {
+		c := listenTestJournal(t)
+		app, close := NewAppenderWithConfig(AppenderConfig{
+			SocketPath:     c.LocalAddr().String(),
+			BufferCapacity: 100,
+			FlushThreshold: 1,
+		})
+		defer close()
+		require.Equal(t, 100, app.(*appender).buf.Cap())
+
+		// Exceeds the flush threshold.
+		require.NoError(t, app.Append(logf.Entry{Level: logf.LevelInfo, Text: "1"}))
+
+		msgs := readTestMsgs(t, c, 1)
+		require.Equal(t, "1", decodeTestEntry(t, msgs[0])["MESSAGE"])
+	})
+}
+
+func TestAppenderOneEntryPerMessage(t *testing.T) {
+	j := &testJournal{}
+	app := newTestAppender(AppenderConfig{}, j)
+
+	texts := []string{"first", "second\nwith newline", "third"}
+	for _, text := range texts {
+		require.NoError(t, app.Append(logf.Entry{Level: logf.LevelInfo, Text: text}))
+	}
+	require.Empty(t, j.msgs)
+
+	require.NoError(t, app.Flush())
+	require.Empty(t, app.buf.Len())
+	require.Empty(t, app.ends)
+	require.Len(t, j.msgs, len(texts))
+
+	for i, msg := range j.msgs {
+		fields := decodeTestEntry(t, msg)
+		require.Equal(t, texts[i], fields["MESSAGE"])
+		require.Equal(t, "6", fields["PRIORITY"])
+	}
+}
+
+func TestAppenderFlushError(t *testing.T) {
+	errTest := errors.New("test")
+	j := &testJournal{err: errTest}
+	app := newTestAppender(AppenderConfig{}, j)
+
+	require.NoError(t, app.Append(logf.Entry{Text: "1"}))
+	require.NoError(t, app.Append(logf.Entry{Text: "2"}))
+
+	err := app.Flush()
+	var flushErr *FlushError
+	require.True(t, errors.As(err, &flushErr))
+	require.Equal(t, 2, flushErr.Total)
+	require.Equal(t, []EntryError{{0, errTest}, {1, errTest}}, flushErr.Entries)
+	require.True(t, errors.Is(err, errTest))
+	require.Len(t, j.msgs, 2)
+	require.Empty(t, app.buf.Len())
+}
+
+func TestAppenderFlushPartialError(t *testing.T) {
+	errTest := errors.New("test")
+	j := &testBatchJournal{errs: map[int]error{1: errTest}}
+	app := newTestAppender(AppenderConfig{}, j)
+
+	for _, text := range []string{"1", "2", "3"} {
+		require.NoError(t, app.Append(logf.Entry{Text: text}))
+	}
+
+	// Only the failed entry in the middle of the batch is reported.
+	err := app.Flush()
+	var flushErr *FlushError
+	require.True(t, errors.As(err, &flushErr))
+	require.Equal(t, 3, flushErr.Total)
+	require.Equal(t, []EntryError{{1, errTest}}, flushErr.Entries)
+	require.Len(t, j.msgs, 2)
+	require.Equal(t, "1", decodeTestEntry(t, j.msgs[0])["MESSAGE"])
+	require.Equal(t, "3", decodeTestEntry(t, j.msgs[1])["MESSAGE"])
+}
+
+// newTestAppender creates the appender with the given config that writes
+// to the given journal.
+func newTestAppender(c AppenderConfig, j journal) *appender {
+	app, _ := NewAppenderWithConfig(c)
+	a := app.(*appender)
+	a.j = j
+
+	return a
+}
+
+type testJournal struct {
+	msgs [][]byte
+	err  error
+}
+
+func (j *testJournal) WriteMsg(b []byte) error {
+	j.msgs = append(j.msgs, append([]byte(nil), b...))
+
+	return j.err
+}
+
+func (j *testJournal) Close() error {
+	return nil
+}
+
+// testBatchJournal is the batchJournal that fails to write messages with
+// the given positions in the batch.
+type testBatchJournal struct {
+	testJournal
+	errs map[int]error
+}
+
+func (j *testBatchJournal) WriteMsgs(msgs [][]byte) []EntryError {
+	var errs []EntryError
+	for i, msg := range msgs {
+		if err, ok := j.errs[i]; ok {
+			errs = append(errs, EntryError{Index: i, Err: err})
+
+			continue
+		}
+		j.WriteMsg(msg)
+	}
+
+	return errs
+}
+
+// decodeTestEntry decodes a single journal entry into a map.
+func decodeTestEntry(t *testing.T, b []byte) map[string]string {
+	fields, err := Decode(b)
+	require.NoError(t, err)
+
+	m := make(map[string]string)
+	for _, f := range fields {
+		m[f.Key] = string(f.Value)
+	}
+
+	return m
+}
+
+// encodeTestFields encodes the entry with the given Encoder and decodes
+// its fields in order. The entry is encoded twice to check that cached keys
+// and derived fields give the same result. The size of the entry is
+// checked against MaxEntrySize.
+func encodeTestFields(t *testing.T, enc logf.Encoder, e logf.Entry) []Field {
+	b := logf.NewBuffer()
+	require.NoError(t, enc.Encode(b, e))
+	first := append([]byte(nil), b.Bytes()...)
+	b.Reset()
+	require.NoError(t, enc.Encode(b, e))
+	require.Equal(t, first, b.Bytes())
+	if max := enc.(*encoder).MaxEntrySize; max > 0 {
+		require.LessOrEqual(t, b.Len(), max)
+	}
+
+	fields, err := Decode(b.Bytes())
+	require.NoError(t, err)
+
+	return fields
+}
+
+// encodeTestEntry is like encodeTestFields but decodes the entry into
+// values by keys. Repeated fields keep all of their values in order.
+func encodeTestEntry(t *testing.T, enc logf.Encoder, e logf.Entry) map[string][]string {
+	m := make(map[string][]string)
+	for _, f := range encodeTestFields(t, enc, e) {
+		m[f.Key] = append(m[f.Key], string(f.Value))
+	}
+
+	return m
+}
+
+// requireNoAllocs checks that the Encoder encodes the entry without
+// allocations.
+func requireNoAllocs(t *testing.T, enc logf.Encoder, e logf.Entry) {
+	b := logf.NewBuffer()
+	require.NoError(t, enc.Encode(b, e))
+
+	allocs := testing.AllocsPerRun(10, func() {
+		b.Reset()
+		enc.Encode(b, e)
+	})
+	require.Zero(t, allocs)
+}
@@ -0,0 +1,167 @@
+package logfjournald
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ssgreg/logf"
+)
+
+// OutputMode specifies where the automatically chosen Appender writes
+// entries to.
+type OutputMode int
+
+// Available output modes.
+const (
+	// OutputModeStream means entries are written to a stream such as
+	// stderr with the stream Encoder.
+	OutputModeStream OutputMode = iota
+
+	// OutputModeJournal means entries are written to the journal socket
+	// using the native protocol.
+	OutputModeJournal
+)
+
+// String implements fmt.Stringer.
+func (m OutputMode) String() string {
+	switch m {
+	case OutputModeStream:
+		return "stream"
+	case OutputModeJournal:
+		return "journal"
+	default:
+		return "unknown"
+	}
+}
+
+// OutputDecision describes the choice of the output mode made by
+// DetectOutputMode.
+type OutputDecision struct {
+	Mode OutputMode
+
+	// JournalStream is the value of the JOURNAL_STREAM environment
+	// variable.
+	JournalStream string
+
+	// Reason is a human readable explanation of the decision.
+	Reason string
+}
+
+// DetectOutputMode checks whether the process's stderr is connected to
+// the journal. systemd sets JOURNAL_STREAM=<device>:<inode> for the
+// services with stdout or stderr connected to the journal. The variable
+// is inherited by child processes, so the device and inode numbers are
+// compared with stderr's ones.
+func DetectOutputMode() OutputDecision {
+	return detectOutputMode(os.Getenv("JOURNAL_STREAM"), os.Stderr)
+}
+
+func detectOutputMode(journalStream string, stderr *os.File) OutputDecision {
+	d := OutputDecision{Mode: OutputModeStream, JournalStream: journalStream}
+	if journalStream == "" {
+		d.Reason = "JOURNAL_STREAM is not set"
+
+		return d
+	}
+
+	dev, ino, ok := parseJournalStream(journalStream)
+	if !ok {
+		d.Reason = "JOURNAL_STREAM is malformed"
+
+		return d
+	}
+
+	fi, err := stderr.Stat()
+	if err != nil {
+		d.Reason = "failed to stat stderr: " + err.Error()
+
+		return d
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		d.Reason = "stderr stat is not available"
+
+		return d
+	}
+	if uint64(st.Dev) != dev || uint64(st.Ino) != ino {
+		d.Reason = "stderr does not match JOURNAL_STREAM"
+
+		return d
+	}
+
+	d.Mode = OutputModeJournal
+	d.Reason = "stderr is connected to the journal"
+
+	return d
+}
+
+// parseJournalStream parses the JOURNAL_STREAM value in form of
+// <device>:<inode>.
+func parseJournalStream(s string) (dev, ino uint64, ok bool) {
+	i := strings.IndexByte(s, ':')
+	if i == -1 {
+		return 0, 0, false
+	}
+	dev, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	ino, err = strconv.ParseUint(s[i+1:], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return dev, ino, true
+}
+
+// AutoAppenderConfig allows to configure the automatically chosen
+// Appender.
+type AutoAppenderConfig struct {
+	// Journal configures the journal Appender.
+	Journal AppenderConfig
+
+	// StreamEncoder encodes entries if the process is not running under
+	// systemd. Default is logf JSON Encoder.
+	StreamEncoder logf.Encoder
+
+	// StreamWriter is the destination for entries if the process is not
+	// running under systemd. Default is os.Stderr.
+	StreamWriter io.Writer
+}
+
+// WithDefaults returns the new config in which all uninitialized fields are
+// filled with their default values.
+func (c AutoAppenderConfig) WithDefaults() AutoAppenderConfig {
+	c.Journal = c.Journal.WithDefaults()
+	if c.StreamEncoder == nil {
+		c.StreamEncoder = logf.NewJSONEncoder.Default()
+	}
+	if c.StreamWriter == nil {
+		c.StreamWriter = os.Stderr
+	}
+
+	return c
+}
+
+// NewAutoAppender creates the journal Appender if the process's stderr is
+// connected to the journal and logf write Appender otherwise. The
+// returned OutputDecision describes the choice.
+func NewAutoAppender(c AutoAppenderConfig) (logf.Appender, AppenderCloseFunc, OutputDecision) {
+	return newAutoAppender(c, DetectOutputMode())
+}
+
+func newAutoAppender(c AutoAppenderConfig, d OutputDecision) (logf.Appender, AppenderCloseFunc, OutputDecision) {
+	c = c.WithDefaults()
+	if d.Mode == OutputModeJournal {
+		a, close := NewAppenderWithConfig(c.Journal)
+
+		return a, close, d
+	}
+
+	return logf.NewWriteAppender(c.StreamWriter, c.StreamEncoder), AppenderCloseFunc(func() error {
+		return nil
+	}), d
+}
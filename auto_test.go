@@ -0,0 +1,77 @@
+package logfjournald
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectOutputMode(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	st := fi.Sys().(*syscall.Stat_t)
+	stream := fmt.Sprintf("%d:%d", uint64(st.Dev), uint64(st.Ino))
+
+	testCases := []struct {
+		Name          string
+		JournalStream string
+		Mode          OutputMode
+	}{
+		{"NotSet", "", OutputModeStream},
+		{"Malformed", "1-2", OutputModeStream},
+		{"MalformedInode", "1:x", OutputModeStream},
+		{"Inherited", fmt.Sprintf("%d:%d", uint64(st.Dev), uint64(st.Ino)+1), OutputModeStream},
+		{"Journal", stream, OutputModeJournal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			d := detectOutputMode(tc.JournalStream, f)
+			require.Equal(t, tc.Mode, d.Mode, d.Reason)
+			require.Equal(t, tc.JournalStream, d.JournalStream)
+			require.NotEmpty(t, d.Reason)
+		})
+	}
+}
+
+func TestOutputModeString(t *testing.T) {
+	require.Equal(t, "stream", OutputModeStream.String())
+	require.Equal(t, "journal", OutputModeJournal.String())
+	require.Equal(t, "unknown", OutputMode(-1).String())
+}
+
+func TestNewAutoAppender(t *testing.T) {
+	t.Run("Stream", func(t *testing.T) {
+		var out bytes.Buffer
+		app, close, d := newAutoAppender(AutoAppenderConfig{StreamWriter: &out}, OutputDecision{Mode: OutputModeStream})
+		defer close()
+		require.Equal(t, OutputModeStream, d.Mode)
+
+		require.NoError(t, app.Append(logf.Entry{Level: logf.LevelInfo, Text: "m"}))
+		require.NoError(t, app.Flush())
+		require.Contains(t, out.String(), `"msg":"m"`)
+	})
+
+	t.Run("Journal", func(t *testing.T) {
+		c := listenTestJournal(t)
+		app, close, d := newAutoAppender(AutoAppenderConfig{
+			Journal: AppenderConfig{SocketPath: c.LocalAddr().String()},
+		}, OutputDecision{Mode: OutputModeJournal})
+		defer close()
+		require.Equal(t, OutputModeJournal, d.Mode)
+
+		require.NoError(t, app.Append(logf.Entry{Level: logf.LevelInfo, Text: "m"}))
+		require.NoError(t, app.Flush())
+		require.Equal(t, "m", decodeTestEntry(t, readTestMsgs(t, c, 1)[0])["MESSAGE"])
+	})
+}
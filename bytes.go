@@ -0,0 +1,30 @@
+package logfjournald
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/ssgreg/logf"
+)
+
+// BytesEncoder is the function type to encode the given byte slice to the
+// value of a journal field.
+type BytesEncoder func(buf *logf.Buffer, v []byte)
+
+// RawBytesEncoder writes the byte slice as is. The journal native protocol
+// is binary safe, so the original bytes could be retrieved with
+// journalctl --output=export.
+func RawBytesEncoder(buf *logf.Buffer, v []byte) {
+	buf.AppendBytes(v)
+}
+
+// Base64BytesEncoder writes the byte slice in the standard base64
+// encoding.
+func Base64BytesEncoder(buf *logf.Buffer, v []byte) {
+	base64.StdEncoding.Encode(buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+}
+
+// HexBytesEncoder writes the byte slice in lowercase hexadecimal encoding.
+func HexBytesEncoder(buf *logf.Buffer, v []byte) {
+	hex.Encode(buf.ExtendBytes(hex.EncodedLen(len(v))), v)
+}
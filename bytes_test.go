@@ -0,0 +1,34 @@
+package logfjournald
+
+import (
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytesEncoders(t *testing.T) {
+	v := []byte{0x00, '\n', 0xff, 'a'}
+	encode := func(enc BytesEncoder) string {
+		b := logf.NewBuffer()
+		enc(b, v)
+
+		return b.String()
+	}
+
+	require.Equal(t, string(v), encode(RawBytesEncoder))
+	require.Equal(t, "AAr/YQ==", encode(Base64BytesEncoder))
+	require.Equal(t, "000aff61", encode(HexBytesEncoder))
+}
+
+func TestEncoderEncodeBytes(t *testing.T) {
+	v := []byte{0x00, '\n', 0xff, 'a'}
+	e := logf.Entry{Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{logf.ConstBytes("frame", v)}}
+	encode := func(c EncoderConfig) []string {
+		return encodeTestEntry(t, newTestEncoder(c), e)["FRAME"]
+	}
+
+	require.Equal(t, []string{"AAr/YQ=="}, encode(EncoderConfig{}))
+	require.Equal(t, []string{string(v)}, encode(EncoderConfig{EncodeBytes: RawBytesEncoder}))
+	require.Equal(t, []string{"000aff61"}, encode(EncoderConfig{EncodeBytes: HexBytesEncoder}))
+}
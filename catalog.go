@@ -0,0 +1,157 @@
+package logfjournald
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// CatalogEntry describes a message type in the journal message catalog.
+// See https://www.freedesktop.org/wiki/Software/systemd/catalog/.
+type CatalogEntry struct {
+	ID MessageID
+
+	// Language is an optional locale of the entry, e.g. "de".
+	Language string
+
+	Subject   string
+	DefinedBy string
+	Support   string
+
+	// Body is the explanation of the message. It may reference fields of
+	// the journal entry as @FIELD@.
+	Body string
+}
+
+// Catalog is a set of CatalogEntry that allows to generate the content of
+// a journal .catalog file. The Catalog is not goroutine safe.
+//
+// Example:
+//
+//	var catalog logfjournald.Catalog
+//
+//	var UserCreated = catalog.MustRegister(logfjournald.CatalogEntry{
+//		ID:      logfjournald.MustParseMessageID("6b1a5fea1a6f4b2a9c2a9f9b6c0b1e7a"),
+//		Subject: "User @USER@ created",
+//		Body:    "The new user account has been created.",
+//	})
+//
+//	logger.Info("user created", logfjournald.MessageIDField(UserCreated))
+type Catalog struct {
+	entries []CatalogEntry
+}
+
+// Register adds the given CatalogEntry to the Catalog. It fails if an entry
+// with the same ID and Language is registered already or the entry could
+// not be represented in the catalog format.
+func (c *Catalog) Register(e CatalogEntry) error {
+	if err := validateCatalogEntry(e); err != nil {
+		return err
+	}
+	for _, o := range c.entries {
+		if o.ID == e.ID && o.Language == e.Language {
+			return errors.New("logfjournald: duplicate catalog entry " + e.ID.String())
+		}
+	}
+	c.entries = append(c.entries, e)
+
+	return nil
+}
+
+// MustRegister is like Register but panics in case of error. It returns
+// the ID of the given CatalogEntry to simplify initialization of global
+// variables.
+func (c *Catalog) MustRegister(e CatalogEntry) MessageID {
+	if err := c.Register(e); err != nil {
+		panic(err)
+	}
+
+	return e.ID
+}
+
+// Entries returns all registered entries in order of registration.
+func (c *Catalog) Entries() []CatalogEntry {
+	return append([]CatalogEntry(nil), c.entries...)
+}
+
+// WriteTo writes registered entries to the given Writer in the journal
+// .catalog file format.
+func (c *Catalog) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for i, e := range c.entries {
+		if i != 0 {
+			bw.WriteByte('\n')
+		}
+		writeCatalogEntry(bw, e)
+	}
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+func writeCatalogEntry(w *bufio.Writer, e CatalogEntry) {
+	w.WriteString("-- ")
+	w.WriteString(e.ID.String())
+	if e.Language != "" {
+		w.WriteByte(' ')
+		w.WriteString(e.Language)
+	}
+	w.WriteByte('\n')
+
+	writeCatalogHeader(w, "Subject", e.Subject)
+	writeCatalogHeader(w, "Defined-By", e.DefinedBy)
+	writeCatalogHeader(w, "Support", e.Support)
+
+	if body := strings.TrimSpace(e.Body); body != "" {
+		w.WriteByte('\n')
+		w.WriteString(body)
+		w.WriteByte('\n')
+	}
+}
+
+func writeCatalogHeader(w *bufio.Writer, name, value string) {
+	if value == "" {
+		return
+	}
+	w.WriteString(name)
+	w.WriteString(": ")
+	w.WriteString(value)
+	w.WriteByte('\n')
+}
+
+func validateCatalogEntry(e CatalogEntry) error {
+	prefix := "logfjournald: invalid catalog entry " + e.ID.String() + ": "
+	if e.Subject == "" {
+		return errors.New(prefix + "empty subject")
+	}
+	for _, v := range []string{e.Language, e.Subject, e.DefinedBy, e.Support} {
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New(prefix + "header contains newline")
+		}
+	}
+	if strings.ContainsAny(e.Language, " \t") {
+		return errors.New(prefix + "language contains space")
+	}
+	for _, line := range strings.Split(e.Body, "\n") {
+		if strings.HasPrefix(line, "-- ") {
+			return errors.New(prefix + "body line starts with \"-- \"")
+		}
+	}
+
+	return nil
+}
+
+// countingWriter counts bytes written to the underlying Writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,60 @@
+package logfjournald
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	var c Catalog
+	id := c.MustRegister(CatalogEntry{
+		ID:        MustParseMessageID(testMessageID),
+		Subject:   "User @USER@ created",
+		DefinedBy: "myservice",
+		Support:   "https://example.com/support",
+		Body:      "The new user account has been created.\n\nNo action is required.\n",
+	})
+	require.Equal(t, MustParseMessageID(testMessageID), id)
+	c.MustRegister(CatalogEntry{
+		ID:       id,
+		Language: "de",
+		Subject:  "Benutzer @USER@ angelegt",
+	})
+
+	buf := bytes.NewBuffer(nil)
+	n, err := c.WriteTo(buf)
+	require.NoError(t, err)
+	require.EqualValues(t, buf.Len(), n)
+	require.Equal(t, `-- fc2e22bc6ee647b6b90729ab34a250b1
+Subject: User @USER@ created
+Defined-By: myservice
+Support: https://example.com/support
+
+The new user account has been created.
+
+No action is required.
+
+-- fc2e22bc6ee647b6b90729ab34a250b1 de
+Subject: Benutzer @USER@ angelegt
+`, buf.String())
+	require.Len(t, c.Entries(), 2)
+}
+
+func TestCatalogRegisterErrors(t *testing.T) {
+	var c Catalog
+	id := MustParseMessageID(testMessageID)
+	require.NoError(t, c.Register(CatalogEntry{ID: id, Subject: "s"}))
+
+	for name, e := range map[string]CatalogEntry{
+		"Duplicate":      {ID: id, Subject: "other"},
+		"EmptySubject":   {ID: MessageID{1}},
+		"SubjectNewline": {ID: MessageID{1}, Subject: "a\nb"},
+		"LanguageSpace":  {ID: MessageID{1}, Subject: "s", Language: "d e"},
+		"BodySeparator":  {ID: MessageID{1}, Subject: "s", Body: "text\n-- next"},
+	} {
+		require.Error(t, c.Register(e), name)
+	}
+	require.Len(t, c.Entries(), 1)
+}
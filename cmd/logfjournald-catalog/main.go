@@ -0,0 +1,93 @@
+// Command logfjournald-catalog generates a systemd journal .catalog file
+// from message IDs declared in Go packages.
+//
+// A message ID is a constant holding 32 hexadecimal characters or
+// a variable initialized with logfjournald.MustParseMessageID. It is
+// included in the catalog if its doc comment contains the
+// //logfjournald:catalog directive. The doc comment describes the catalog
+// entry: the first paragraph starting with a Subject, Defined-By or
+// Support header holds headers, all following paragraphs are the body.
+// Paragraphs before headers are ignored and could be used for Go
+// documentation as usual.
+//
+// Example:
+//
+//	// UserCreated is logged when a new user account has been created.
+//	//
+//	// Subject: User @USER@ created
+//	// Defined-By: myservice
+//	//
+//	// The new user account has been created. No action is required.
+//	//
+//	//logfjournald:catalog
+//	const UserCreated = "fc2e22bc6ee647b6b90729ab34a250b1"
+//
+// The directive accepts an optional language of the entry, e.g.
+// //logfjournald:catalog de.
+//
+// Usage:
+//
+//	logfjournald-catalog [flags] [packages]
+//
+// Packages are directories. A directory followed by /... is scanned
+// recursively. The command fails if two declarations share the same
+// message ID and language.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	output := flag.String("o", "", "write the catalog to `file` instead of stdout")
+	definedBy := flag.String("defined-by", "", "default Defined-By `header` for entries without one")
+	support := flag.String("support", "", "default Support `header` for entries without one")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: logfjournald-catalog [flags] [packages]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	s := newScanner()
+	s.DefinedBy = *definedBy
+	s.Support = *support
+
+	if err := run(s, patterns, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "logfjournald-catalog:", err)
+		os.Exit(1)
+	}
+}
+
+func run(s *scanner, patterns []string, output string) error {
+	dirs, err := expandPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if err := s.scanDir(dir); err != nil {
+			return err
+		}
+	}
+
+	// Write nothing unless the whole catalog is generated.
+	buf := bytes.NewBuffer(nil)
+	if _, err := s.catalog.WriteTo(buf); err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+
+		return err
+	}
+
+	return ioutil.WriteFile(output, buf.Bytes(), 0644)
+}
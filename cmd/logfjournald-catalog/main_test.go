@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	s := newScanner()
+	s.DefinedBy = "default"
+	output := filepath.Join(t.TempDir(), "service.catalog")
+
+	require.NoError(t, run(s, []string{"testdata/valid/..."}, output))
+
+	b, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, `-- fc2e22bc6ee647b6b90729ab34a250b1
+Subject: User @USER@ created
+Defined-By: myservice
+
+The new user account has been created.
+
+No action is required.
+
+-- fc2e22bc6ee647b6b90729ab34a250b1 de
+Subject: Benutzer @USER@ angelegt
+Defined-By: default
+
+-- 0a5c1ad4a6c04ee38a3b3f5e0c1d9e21
+Subject: Disk is full
+Defined-By: default
+Support: https://example.com/disk
+
+-- 4d3f1b1f2a9e4e4b9f3f3c1b6d7e8f90
+Subject: Request failed
+Defined-By: default
+`, string(b))
+}
+
+func TestRunErrors(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "service.catalog")
+
+	err := run(newScanner(), []string{"testdata/duplicate"}, output)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "duplicate message ID fc2e22bc6ee647b6b90729ab34a250b1")
+	require.Contains(t, err.Error(), "b.go")
+
+	err = run(newScanner(), []string{"testdata/invalid"}, output)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Bad")
+
+	require.NoFileExists(t, output)
+}
+
+func TestParseCatalogDoc(t *testing.T) {
+	e, err := parseCatalogDoc("Doc.\n\nSubject: s\nSupport: u\n\nBody.\n")
+	require.NoError(t, err)
+	require.Equal(t, "s", e.Subject)
+	require.Equal(t, "u", e.Support)
+	require.Equal(t, "Body.", e.Body)
+
+	_, err = parseCatalogDoc("Doc only.\n")
+	require.Error(t, err)
+	_, err = parseCatalogDoc("Subject: s\nUnknown: x\n")
+	require.Error(t, err)
+}
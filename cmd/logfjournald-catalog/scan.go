@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ssgreg/logfjournald"
+)
+
+const catalogDirective = "//logfjournald:catalog"
+
+// scanner collects catalog entries from Go source files.
+type scanner struct {
+	// DefinedBy and Support are used for entries without such headers.
+	DefinedBy string
+	Support   string
+
+	fset    *token.FileSet
+	catalog logfjournald.Catalog
+	seen    map[string]token.Position
+}
+
+func newScanner() *scanner {
+	return &scanner{
+		fset: token.NewFileSet(),
+		seen: make(map[string]token.Position),
+	}
+}
+
+// expandPatterns converts the given patterns to the sorted list of
+// directories. A pattern ending with /... matches the directory and all
+// its subdirectories except testdata, vendor and hidden ones.
+func expandPatterns(patterns []string) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, p := range patterns {
+		if p != "..." && !strings.HasSuffix(p, "/...") {
+			add(filepath.Clean(p))
+
+			continue
+		}
+		root := filepath.Clean(strings.TrimSuffix(strings.TrimSuffix(p, "..."), "/"))
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			name := info.Name()
+			if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			add(path)
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(dirs)
+
+	return dirs, nil
+}
+
+// scanDir scans all non-test Go files in the given directory.
+func (s *scanner) scanDir(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(s.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		if err := s.scanFile(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *scanner) scanFile(f *ast.File) error {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || (gd.Tok != token.CONST && gd.Tok != token.VAR) {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			doc := vs.Doc
+			if doc == nil && !gd.Lparen.IsValid() {
+				doc = gd.Doc
+			}
+			language, ok := catalogLanguage(doc)
+			if !ok {
+				continue
+			}
+			for i, name := range vs.Names {
+				if err := s.addEntry(name, vs.Values, i, language, doc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *scanner) addEntry(name *ast.Ident, values []ast.Expr, i int, language string, doc *ast.CommentGroup) error {
+	pos := s.fset.Position(name.Pos())
+	fail := func(err error) error {
+		return fmt.Errorf("%s: %s: %v", pos, name.Name, err)
+	}
+
+	if i >= len(values) {
+		return fail(errors.New("message ID has no value"))
+	}
+	text, ok := messageIDLiteral(values[i])
+	if !ok {
+		return fail(errors.New("message ID must be a string literal or a MustParseMessageID call with a string literal"))
+	}
+	id, err := logfjournald.ParseMessageID(text)
+	if err != nil {
+		return fail(err)
+	}
+
+	e, err := parseCatalogDoc(doc.Text())
+	if err != nil {
+		return fail(err)
+	}
+	e.ID = id
+	e.Language = language
+	if e.DefinedBy == "" {
+		e.DefinedBy = s.DefinedBy
+	}
+	if e.Support == "" {
+		e.Support = s.Support
+	}
+
+	key := id.String() + " " + language
+	if prev, ok := s.seen[key]; ok {
+		return fail(fmt.Errorf("duplicate message ID %s, previous declaration at %s", id, prev))
+	}
+	if err := s.catalog.Register(e); err != nil {
+		return fail(err)
+	}
+	s.seen[key] = pos
+
+	return nil
+}
+
+// catalogLanguage reports whether the given doc comment contains the
+// catalog directive and returns its optional language argument.
+func catalogLanguage(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		if c.Text == catalogDirective {
+			return "", true
+		}
+		if strings.HasPrefix(c.Text, catalogDirective+" ") {
+			return strings.TrimSpace(strings.TrimPrefix(c.Text, catalogDirective)), true
+		}
+	}
+
+	return "", false
+}
+
+// messageIDLiteral returns the string literal the given expression is
+// built from. Both "..." and [pkg.]MustParseMessageID("...") are allowed.
+func messageIDLiteral(expr ast.Expr) (string, bool) {
+	if call, ok := expr.(*ast.CallExpr); ok {
+		var fn string
+		switch f := call.Fun.(type) {
+		case *ast.Ident:
+			fn = f.Name
+		case *ast.SelectorExpr:
+			fn = f.Sel.Name
+		}
+		if fn != "MustParseMessageID" || len(call.Args) != 1 {
+			return "", false
+		}
+		expr = call.Args[0]
+	}
+
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	text, err := strconv.Unquote(lit.Value)
+
+	return text, err == nil
+}
+
+// parseCatalogDoc builds the catalog entry from the doc comment text.
+func parseCatalogDoc(text string) (logfjournald.CatalogEntry, error) {
+	var e logfjournald.CatalogEntry
+
+	paragraphs := strings.Split(strings.TrimSpace(text), "\n\n")
+	for i, p := range paragraphs {
+		if !isCatalogHeader(p) {
+			continue
+		}
+		for _, line := range strings.Split(p, "\n") {
+			kv := strings.SplitN(line, ":", 2)
+			if len(kv) != 2 {
+				return e, fmt.Errorf("malformed catalog header %q", line)
+			}
+			value := strings.TrimSpace(kv[1])
+			switch kv[0] {
+			case "Subject":
+				e.Subject = value
+			case "Defined-By":
+				e.DefinedBy = value
+			case "Support":
+				e.Support = value
+			default:
+				return e, fmt.Errorf("unknown catalog header %q", kv[0])
+			}
+		}
+		e.Body = strings.Join(paragraphs[i+1:], "\n\n")
+
+		return e, nil
+	}
+
+	return e, errors.New("no catalog headers found in doc comment")
+}
+
+func isCatalogHeader(p string) bool {
+	for _, h := range []string{"Subject:", "Defined-By:", "Support:"} {
+		if strings.HasPrefix(p, h) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,6 @@
+package duplicate
+
+// Subject: First
+//
+//logfjournald:catalog
+const First = "fc2e22bc6ee647b6b90729ab34a250b1"
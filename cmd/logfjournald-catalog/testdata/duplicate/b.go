@@ -0,0 +1,6 @@
+package duplicate
+
+// Subject: Second
+//
+//logfjournald:catalog
+const Second = "FC2E22BC6EE647B6B90729AB34A250B1"
@@ -0,0 +1,6 @@
+package invalid
+
+// Subject: Bad
+//
+//logfjournald:catalog
+const Bad = "fc2e22bc"
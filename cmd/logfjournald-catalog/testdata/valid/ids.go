@@ -0,0 +1,33 @@
+package valid
+
+import "github.com/ssgreg/logfjournald"
+
+// UserCreated is logged when a new user account has been created.
+//
+// Subject: User @USER@ created
+// Defined-By: myservice
+//
+// The new user account has been created.
+//
+// No action is required.
+//
+//logfjournald:catalog
+const UserCreated = "fc2e22bc6ee647b6b90729ab34a250b1"
+
+// UserCreatedDE is the German translation of UserCreated.
+//
+// Subject: Benutzer @USER@ angelegt
+//
+//logfjournald:catalog de
+const UserCreatedDE = "fc2e22bc6ee647b6b90729ab34a250b1"
+
+var (
+	// Subject: Disk is full
+	// Support: https://example.com/disk
+	//
+	//logfjournald:catalog
+	DiskFull = logfjournald.MustParseMessageID("0a5c1ad4a6c04ee38a3b3f5e0c1d9e21")
+
+	// NotInCatalog has no directive.
+	NotInCatalog = logfjournald.MustParseMessageID("9d0fa1b4e1b84d0c9c9d0f7e28c8bb10")
+)
@@ -0,0 +1,6 @@
+package sub
+
+// Subject: Request failed
+//
+//logfjournald:catalog
+const RequestFailed = "4d3f1b1f2a9e4e4b9f3f3c1b6d7e8f90"
@@ -0,0 +1,107 @@
+package logfjournald
+
+// CollisionPolicy defines how the journal Encoder handles user fields with
+// keys that collide with reserved fields after normalization, e.g. a user
+// field "message" that would duplicate the native MESSAGE field.
+type CollisionPolicy int
+
+// Available collision policies.
+const (
+	// CollisionPolicyPrefix prepends CollisionAffix to the key, e.g.
+	// LOGF_MESSAGE.
+	CollisionPolicyPrefix CollisionPolicy = iota
+
+	// CollisionPolicySuffix appends CollisionAffix to the key, e.g.
+	// MESSAGE_LOGF.
+	CollisionPolicySuffix
+
+	// CollisionPolicyDrop drops the field.
+	CollisionPolicyDrop
+
+	// CollisionPolicyError drops the whole entry. Encode returns
+	// a *KeyCollisionError. StaticFields are encoded once for all
+	// entries, so CollisionPolicyPrefix applies to them instead.
+	CollisionPolicyError
+
+	// CollisionPolicyAllow writes the field as is.
+	CollisionPolicyAllow
+)
+
+// String implements fmt.Stringer.
+func (p CollisionPolicy) String() string {
+	switch p {
+	case CollisionPolicyPrefix:
+		return "prefix"
+	case CollisionPolicySuffix:
+		return "suffix"
+	case CollisionPolicyDrop:
+		return "drop"
+	case CollisionPolicyError:
+		return "error"
+	case CollisionPolicyAllow:
+		return "allow"
+	}
+
+	return "unknown"
+}
+
+// reservedFieldKeys holds well-known fields of the systemd journal that
+// could be written by clients. See systemd.journal-fields(7).
+var reservedFieldKeys = map[string]struct{}{
+	// User journal fields.
+	"MESSAGE":            {},
+	"MESSAGE_ID":         {},
+	"PRIORITY":           {},
+	"CODE_FILE":          {},
+	"CODE_LINE":          {},
+	"CODE_FUNC":          {},
+	"ERRNO":              {},
+	"INVOCATION_ID":      {},
+	"USER_INVOCATION_ID": {},
+	"SYSLOG_FACILITY":    {},
+	"SYSLOG_IDENTIFIER":  {},
+	"SYSLOG_PID":         {},
+	"SYSLOG_TIMESTAMP":   {},
+	"SYSLOG_RAW":         {},
+	"DOCUMENTATION":      {},
+	"TID":                {},
+	"UNIT":               {},
+	"USER_UNIT":          {},
+
+	// Kernel fields.
+	"KERNEL_DEVICE":    {},
+	"KERNEL_SUBSYSTEM": {},
+	"UDEV_SYSNAME":     {},
+	"UDEV_DEVNODE":     {},
+	"UDEV_DEVLINK":     {},
+
+	// Fields to log on behalf of a different program.
+	"COREDUMP_UNIT":                {},
+	"COREDUMP_USER_UNIT":           {},
+	"OBJECT_PID":                   {},
+	"OBJECT_UID":                   {},
+	"OBJECT_GID":                   {},
+	"OBJECT_COMM":                  {},
+	"OBJECT_EXE":                   {},
+	"OBJECT_CMDLINE":               {},
+	"OBJECT_AUDIT_SESSION":         {},
+	"OBJECT_AUDIT_LOGINUID":        {},
+	"OBJECT_SYSTEMD_CGROUP":        {},
+	"OBJECT_SYSTEMD_SESSION":       {},
+	"OBJECT_SYSTEMD_OWNER_UID":     {},
+	"OBJECT_SYSTEMD_UNIT":          {},
+	"OBJECT_SYSTEMD_USER_UNIT":     {},
+	"OBJECT_SYSTEMD_INVOCATION_ID": {},
+}
+
+// IsReservedFieldKey reports whether the given normalized key is one of the
+// systemd journal well-known fields. Trusted fields starting with an
+// underscore are reserved as well.
+func IsReservedFieldKey(key string) bool {
+	if len(key) != 0 && key[0] == '_' {
+		return true
+	}
+	_, ok := reservedFieldKeys[key]
+
+	return ok
+}
@@ -0,0 +1,191 @@
+package logfjournald
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReservedFieldKey(t *testing.T) {
+	for _, k := range []string{"MESSAGE", "PRIORITY", "CODE_FILE", "SYSLOG_IDENTIFIER", "OBJECT_PID", "_PID", "__CURSOR"} {
+		require.True(t, IsReservedFieldKey(k), k)
+	}
+	for _, k := range []string{"", "LOGF_PID", "message", "LEVEL", "USER_ID"} {
+		require.False(t, IsReservedFieldKey(k), k)
+	}
+}
+
+func TestCollisionPolicyString(t *testing.T) {
+	require.Equal(t, "prefix", CollisionPolicyPrefix.String())
+	require.Equal(t, "suffix", CollisionPolicySuffix.String())
+	require.Equal(t, "drop", CollisionPolicyDrop.String())
+	require.Equal(t, "error", CollisionPolicyError.String())
+	require.Equal(t, "allow", CollisionPolicyAllow.String())
+	require.Equal(t, "unknown", CollisionPolicy(42).String())
+}
+
+func TestEncoderCollisionPolicy(t *testing.T) {
+	entry := logf.Entry{
+		Level: logf.LevelInfo,
+		Text:  "m",
+		Fields: []logf.Field{
+			logf.String("message", "user message"),
+			logf.Int("priority", 1),
+			logf.String("level", "user level"),
+			logf.String("_pid", "1"),
+			logf.String("user", "x"),
+			MessageIDField(MustParseMessageID(testMessageID)),
+		},
+	}
+
+	encode := func(c EncoderConfig) []Field {
+		return encodeTestFields(t, newTestEncoder(c), entry)
+	}
+
+	t.Run("Prefix", func(t *testing.T) {
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"LOGF_MESSAGE", []byte("user message")},
+			{"LOGF_PRIORITY", []byte("1")},
+			{"LOGF_LEVEL", []byte("user level")},
+			{"LOGF_PID", []byte("1")},
+			{"USER", []byte("x")},
+			{"MESSAGE_ID", []byte(testMessageID)},
+		}, encode(EncoderConfig{}))
+	})
+
+	t.Run("PrefixAffix", func(t *testing.T) {
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"APP_MESSAGE", []byte("user message")},
+			{"APP_PRIORITY", []byte("1")},
+			{"APP_LEVEL", []byte("user level")},
+			{"LOGF_PID", []byte("1")},
+			{"USER", []byte("x")},
+			{"MESSAGE_ID", []byte(testMessageID)},
+		}, encode(EncoderConfig{CollisionAffix: "app"}))
+	})
+
+	t.Run("Suffix", func(t *testing.T) {
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"MESSAGE_LOGF", []byte("user message")},
+			{"PRIORITY_LOGF", []byte("1")},
+			{"LEVEL_LOGF", []byte("user level")},
+			{"LOGF_PID", []byte("1")},
+			{"USER", []byte("x")},
+			{"MESSAGE_ID", []byte(testMessageID)},
+		}, encode(EncoderConfig{CollisionPolicy: CollisionPolicySuffix}))
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"LOGF_PID", []byte("1")},
+			{"USER", []byte("x")},
+			{"MESSAGE_ID", []byte(testMessageID)},
+		}, encode(EncoderConfig{CollisionPolicy: CollisionPolicyDrop}))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		b := logf.NewBuffer()
+		err := newTestEncoder(EncoderConfig{CollisionPolicy: CollisionPolicyError}).Encode(b, entry)
+		require.Zero(t, b.Len())
+		var kce *KeyCollisionError
+		require.True(t, errors.As(err, &kce))
+		require.Equal(t, &KeyCollisionError{Key: "message", Field: "MESSAGE"}, kce)
+		require.EqualError(t, err, `logfjournald: field "message" collides with reserved field MESSAGE`)
+	})
+
+	t.Run("Allow", func(t *testing.T) {
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"MESSAGE", []byte("user message")},
+			{"PRIORITY", []byte("1")},
+			{"LEVEL", []byte("user level")},
+			{"LOGF_PID", []byte("1")},
+			{"USER", []byte("x")},
+			{"MESSAGE_ID", []byte(testMessageID)},
+		}, encode(EncoderConfig{CollisionPolicy: CollisionPolicyAllow}))
+	})
+
+	t.Run("DisabledEncoderField", func(t *testing.T) {
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"LOGF_MESSAGE", []byte("user message")},
+			{"LOGF_PRIORITY", []byte("1")},
+			{"LEVEL", []byte("user level")},
+			{"LOGF_PID", []byte("1")},
+			{"USER", []byte("x")},
+			{"MESSAGE_ID", []byte(testMessageID)},
+		}, encode(EncoderConfig{DisableFieldLevel: true}))
+	})
+}
+
+type testObject []logf.Field
+
+func (o testObject) EncodeLogfObject(enc logf.FieldEncoder) error {
+	for _, f := range o {
+		f.Accept(enc)
+	}
+
+	return nil
+}
+
+func TestEncoderCollisionFlatten(t *testing.T) {
+	enc := newTestEncoder(EncoderConfig{FlattenObjects: true, CollisionPolicy: CollisionPolicyDrop})
+	b := logf.NewBuffer()
+	require.NoError(t, enc.Encode(b, logf.Entry{
+		Level: logf.LevelInfo,
+		Text:  "m",
+		Fields: []logf.Field{
+			logf.Object("code", testObject{logf.String("file", "f"), logf.String("name", "x")}),
+		},
+	}))
+
+	fields := decodeTestEntry(t, b.Bytes())
+	require.NotContains(t, fields, "CODE_FILE")
+	require.Equal(t, "x", fields["CODE_NAME"])
+}
+
+func TestEncoderCollisionError(t *testing.T) {
+	enc := newTestEncoder(EncoderConfig{CollisionPolicy: CollisionPolicyError})
+	b := logf.NewBuffer()
+	require.NoError(t, enc.Encode(b, logf.Entry{Level: logf.LevelInfo, Text: "first"}))
+	n := b.Len()
+
+	// Derived fields with collisions are not cached.
+	e := logf.Entry{
+		LoggerID:      1,
+		Level:         logf.LevelInfo,
+		Text:          "second",
+		DerivedFields: []logf.Field{logf.String("priority", "x")},
+	}
+	require.Error(t, enc.Encode(b, e))
+	require.Equal(t, n, b.Len())
+	require.Error(t, enc.Encode(b, e))
+	require.Equal(t, n, b.Len())
+
+	// The Encoder is usable after an error.
+	require.NoError(t, enc.Encode(b, logf.Entry{Level: logf.LevelInfo, Text: "third"}))
+	require.NotEqual(t, n, b.Len())
+}
@@ -0,0 +1,86 @@
+package logfjournald
+
+import (
+	"strconv"
+
+	"github.com/ssgreg/logfjournald/internal/native"
+)
+
+// maxFieldKeyLen is the maximum length of a field name accepted by the
+// journal.
+const maxFieldKeyLen = 64
+
+// Field holds a single field of a decoded journal entry.
+type Field struct {
+	Key   string
+	Value []byte
+}
+
+// DecodeError describes malformed journal native protocol data.
+type DecodeError struct {
+	// Offset is the byte offset in the input where the error occurred.
+	Offset int
+	Msg    string
+}
+
+// Error implements error.
+func (e *DecodeError) Error() string {
+	return "logfjournald: malformed journal entry at offset " + strconv.Itoa(e.Offset) + ": " + e.Msg
+}
+
+// Decode decodes the given journal entry encoded with the native
+// protocol. Both "KEY=value" and length-prefixed binary forms of fields
+// are supported. Fields are returned in order of appearance, a key may
+// appear more than once.
+//
+// Decode returns DecodeError if the data is malformed or a field name
+// is not accepted by the journal.
+func Decode(b []byte) ([]Field, error) {
+	var fields []Field
+	var keyErr error
+	err := native.Parse(b, func(k, v []byte) {
+		if keyErr == nil {
+			if msg := checkFieldKey(k); msg != "" {
+				keyErr = &DecodeError{offsetOf(b, k), msg}
+			}
+		}
+		fields = append(fields, Field{string(k), append(make([]byte, 0, len(v)), v...)})
+	})
+	if err != nil {
+		if se, ok := err.(*native.SyntaxError); ok {
+			return nil, &DecodeError{se.Offset, se.Msg}
+		}
+
+		return nil, err
+	}
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	return fields, nil
+}
+
+// checkFieldKey checks the given field name according to the journal
+// rules. It returns the description of the problem or an empty string.
+func checkFieldKey(k []byte) string {
+	switch {
+	case len(k) > maxFieldKeyLen:
+		return "field name " + strconv.Quote(string(k)) + " is longer than " + strconv.Itoa(maxFieldKeyLen) + " bytes"
+	case k[0] == '_':
+		return "field name " + strconv.Quote(string(k)) + " starts with underscore"
+	case k[0] >= '0' && k[0] <= '9':
+		return "field name " + strconv.Quote(string(k)) + " starts with digit"
+	}
+	for _, c := range k {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return "field name " + strconv.Quote(string(k)) + " contains invalid character " + strconv.QuoteRune(rune(c))
+		}
+	}
+
+	return ""
+}
+
+// offsetOf returns the offset of the given sub-slice in b.
+func offsetOf(b, sub []byte) int {
+	return cap(b) - cap(sub)
+}
@@ -0,0 +1,72 @@
+package logfjournald
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	fields, err := Decode([]byte("A=1\nB\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\nA=\n"))
+	require.NoError(t, err)
+	require.Equal(t, []Field{{"A", []byte("1")}, {"B", []byte("a\nb")}, {"A", []byte{}}}, fields)
+
+	fields, err = Decode(nil)
+	require.NoError(t, err)
+	require.Empty(t, fields)
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Data   string
+		Offset int
+	}{
+		{"MissingNewline", "A=1", 0},
+		{"Separator", "A=1\n\nB=2\n", 4},
+		{"TruncatedValue", "A\n\x05\x00\x00\x00\x00\x00\x00\x00ab\n", 10},
+		{"LowerCase", "A=1\nb=2\n", 4},
+		{"Underscore", "_A=1\n", 0},
+		{"Digit", "A=1\nB=2\n1=3\n", 8},
+		{"TooLong", "A=1\nAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=1\n", 4},
+		{"InvalidChar", "A-B\n\x01\x00\x00\x00\x00\x00\x00\x00a\n", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := Decode([]byte(tc.Data))
+
+			var decodeErr *DecodeError
+			require.True(t, errors.As(err, &decodeErr), "%v", err)
+			require.Equal(t, tc.Offset, decodeErr.Offset)
+		})
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	enc := newTestEncoder(EncoderConfig{})
+	b := logf.NewBuffer()
+	require.NoError(t, enc.Encode(b, logf.Entry{
+		Level: logf.LevelInfo,
+		Text:  "multi\nline",
+		Fields: []logf.Field{
+			logf.String("str", "sv"),
+			logf.ConstBytes("bytes", []byte{0, 1}),
+			logf.Int("int", 42),
+		},
+	}))
+
+	fields, err := Decode(b.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, []Field{
+		{"PRIORITY", []byte("6")},
+		{"LEVEL", []byte("info")},
+		{"MESSAGE", []byte("multi\nline")},
+		{"TS", []byte("0001-01-01T00:00:00Z")},
+		{"STR", []byte("sv")},
+		{"BYTES", []byte("AAE=")},
+		{"INT", []byte("42")},
+	}, fields)
+}
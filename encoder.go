@@ -1,12 +1,16 @@
 package logfjournald
 
 import (
-	"encoding/base64"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"runtime"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 
-	"github.com/ssgreg/journald"
 	"github.com/ssgreg/logf"
 )
 
@@ -14,7 +18,7 @@ import (
 // the given EncoderConfig and TypeEncoderFactory for non-basic types.
 var NewEncoder = jsonEncoderGetter(
 	func(c EncoderConfig, mf logf.TypeEncoderFactory) logf.Encoder {
-		return &encoder{c.WithDefaults(), mf, nil, logf.NewCache(100)}
+		return newEncoder(c, mf, logf.NewCache(derivedCacheSize))
 	},
 )
 
@@ -23,7 +27,7 @@ var NewEncoder = jsonEncoderGetter(
 // TypeEncoderFactory for non-basic types.
 var NewTypeEncoderFactory = jsonTypeEncoderFactoryGetter(
 	func(c EncoderConfig, mf logf.TypeEncoderFactory) logf.TypeEncoderFactory {
-		return &encoder{c.WithDefaults(), mf, nil, nil}
+		return newEncoder(c, mf, nil)
 	},
 )
 
@@ -39,17 +43,194 @@ func (c jsonTypeEncoderFactoryGetter) Default() logf.TypeEncoderFactory {
 	return c(EncoderConfig{}, logf.NewJSONTypeEncoderFactory.Default())
 }
 
+// derivedCacheSize is the number of loggers which derived fields are cached
+// by the Encoder.
+const derivedCacheSize = 100
+
 type encoder struct {
 	EncoderConfig
 	mf logf.TypeEncoderFactory
 
 	buf   *logf.Buffer
 	cache *logf.Cache
+
+	// syslog holds precomputed SYSLOG_* fields.
+	syslog []byte
+
+	// static holds precomputed StaticFields. encodingStatic is set while
+	// they are encoded.
+	static         []byte
+	encodingStatic bool
+
+	// reserved holds normalized keys user fields must not collide with.
+	reserved       map[string]struct{}
+	collisionAffix []byte
+
+	// dropping is set if the value of the last added key must be dropped.
+	// err holds the first error occurred while encoding the entry.
+	dropping bool
+	err      error
+
+	// keyPrefix holds normalized keys of objects being flattened joined
+	// with flattenSeparator.
+	keyPrefix        logf.Buffer
+	flattenSeparator []byte
+	depth            int
+
+	// fieldKeyPrefix holds normalized FieldKeyPrefix with a trailing
+	// underscore.
+	fieldKeyPrefix []byte
+
+	// keys caches keyInfo of user fields by keyPrefix and the original
+	// key. cacheKey holds the lookup key.
+	keys     keyCache
+	cacheKey logf.Buffer
+
+	// keyStart and keyEnd hold the position of the last added key.
+	keyStart int
+	keyEnd   int
+
+	// filtering is set if any filtering rule is configured. level holds
+	// the level of the entry being encoded. levelCaches hold derived
+	// fields for each level if there are FieldLevelRules.
+	// includeSubtree is set while fields of a flattened object included
+	// by IncludeFields are encoded.
+	filtering      bool
+	includeSubtree bool
+	level          logf.Level
+	levelCaches    map[logf.Level]*logf.Cache
+
+	// redacting is set if any redaction rule is configured. userField is
+	// set if the last added key belongs to a user field, redactKey is set
+	// if its value must be redacted according to RedactKeys.
+	// redactSubtree is set while fields of a flattened object with such
+	// a key are encoded.
+	// textValue is set while a string or bytes value is encoded. Only
+	// such values are checked with RedactValues.
+	redacting     bool
+	redactSubtree bool
+	userField     bool
+	redactKey     bool
+	textValue     bool
+
+	// mac computes hashes of redacted values with RedactModeHash. macSum
+	// holds the last hash.
+	mac    hash.Hash
+	macSum []byte
+
+	// entryStart holds the position of the entry being encoded.
+	// entryLimited is set if a value was truncated or dropped because of
+	// MaxEntrySize.
+	entryStart   int
+	entryLimited bool
+
+	// precomputing is set while the fields that are the same for all
+	// entries are encoded. MaxEntrySize is not applied to them since it
+	// depends on the entry.
+	precomputing bool
+
+	// repeating is set while elements of a slice are encoded as repeated
+	// fields. repeated counts the elements encoded so far.
+	repeating bool
+	repeated  int
+}
+
+func newEncoder(c EncoderConfig, mf logf.TypeEncoderFactory, cache *logf.Cache) *encoder {
+	f := &encoder{EncoderConfig: c.WithDefaults(), mf: mf, cache: cache}
+
+	// SYSLOG_* fields are the same for all entries. Encode them once.
+	buf := logf.NewBuffer()
+	f.buf = buf
+	f.precomputing = true
+	f.encodeSyslog()
+	f.precomputing = false
+	f.syslog = buf.Bytes()
+	f.buf = nil
+
+	sep := logf.NewBuffer()
+	appendNormalizedKeyPart(sep, f.FlattenSeparator)
+	f.flattenSeparator = sep.Bytes()
+
+	if f.FieldKeyPrefix != "" {
+		prefix := logf.NewBuffer()
+		appendNormalizedKey(prefix, f.FieldKeyPrefix)
+		if prefix.Back() != '_' {
+			prefix.AppendByte('_')
+		}
+		f.fieldKeyPrefix = prefix.Bytes()
+	}
+
+	f.filtering = len(f.IncludeFields) != 0 || len(f.ExcludeFields) != 0 || len(f.FieldLevelRules) != 0
+	f.redacting = len(f.RedactKeys) != 0 || len(f.RedactKeyRegexps) != 0 || len(f.RedactValues) != 0
+	if f.RedactMode == RedactModeHash && len(f.RedactHashKey) != 0 {
+		f.mac = hmac.New(sha256.New, f.RedactHashKey)
+	}
+
+	affix := logf.NewBuffer()
+	appendNormalizedKey(affix, f.CollisionAffix)
+	f.collisionAffix = affix.Bytes()
+
+	f.reserved = make(map[string]struct{}, len(reservedFieldKeys)+4)
+	for k := range reservedFieldKeys {
+		f.reserved[k] = struct{}{}
+	}
+	for k, disabled := range map[string]bool{
+		f.FieldKeyTime:   f.DisableFieldTime,
+		f.FieldKeyLevel:  f.DisableFieldLevel,
+		f.FieldKeyName:   f.DisableFieldName,
+		f.FieldKeyCaller: f.DisableFieldCaller,
+	} {
+		if !disabled {
+			f.reserved[NormalizeKey(k)] = struct{}{}
+		}
+	}
+
+	// StaticFields are the same for all entries as well. Encode them once
+	// like user fields when all the rules above are ready.
+	static := logf.NewBuffer()
+	f.buf = static
+	f.precomputing = true
+	f.encodeStaticFields()
+	f.precomputing = false
+	f.static = static.Bytes()
+	f.buf = nil
+
+	return f
+}
+
+// encodeSyslog encodes SYSLOG_IDENTIFIER, SYSLOG_FACILITY and SYSLOG_PID
+// fields.
+func (f *encoder) encodeSyslog() {
+	if !f.DisableFieldSyslogIdentifier && f.SyslogIdentifier != "" {
+		f.addNativeKey(DefaultFieldKeySyslogIdentifier)
+		f.EncodeTypeString(f.SyslogIdentifier)
+	}
+	if f.SyslogFacility != FacilityKern {
+		f.addNativeKey(DefaultFieldKeySyslogFacility)
+		f.EncodeTypeInt64(int64(f.SyslogFacility))
+	}
+	if f.EnableFieldSyslogPID {
+		f.addNativeKey(DefaultFieldKeySyslogPID)
+		f.EncodeTypeInt64(int64(f.SyslogPID))
+	}
+}
+
+// encodeStaticFields encodes StaticFields. FieldLevelRules are not
+// applied to them since they do not depend on the entry.
+func (f *encoder) encodeStaticFields() {
+	f.encodingStatic = true
+	for _, field := range f.StaticFields {
+		field.Accept(f)
+	}
+	f.encodingStatic = false
 }
 
 // TypeEncoder conforms to TypeEncoderFactory interface.
 func (f *encoder) TypeEncoder(buf *logf.Buffer) logf.TypeEncoder {
 	f.buf = buf
+	f.keyStart, f.keyEnd = 0, 0
+	f.userField, f.redactKey = false, false
+	f.entryStart = buf.Len()
 
 	return f
 }
@@ -57,54 +238,89 @@ func (f *encoder) TypeEncoder(buf *logf.Buffer) logf.TypeEncoder {
 // Encode conforms to Encoder interface.
 func (f *encoder) Encode(buf *logf.Buffer, e logf.Entry) error {
 	f.buf = buf
+	start := buf.Len()
 
 	// There are messages in buffer already. Add message separator.
 	if f.buf.Len() != 0 {
 		f.buf.AppendByte('\n')
 	}
+	f.entryStart = buf.Len()
+	f.level = e.Level
 
 	// PRIORITY.
 	if !f.DisableFieldPriority {
-		f.EncodeFieldInt64(DefaultFieldKeyPriority, int64(f.levelToPriority(e.Level)))
+		f.addNativeKey(DefaultFieldKeyPriority)
+		f.EncodeTypeInt64(int64(f.EncodeLevelPriority(e.Level)))
 	}
 
 	// Level.
 	if !f.DisableFieldLevel {
-		f.addKey(f.FieldKeyLevel)
+		f.addNativeKey(f.FieldKeyLevel)
 		f.EncodeLevel(e.Level, f)
 	}
 
 	// MESSAGE.
-	f.EncodeFieldString(DefaultFieldKeyMessage, e.Text)
+	f.addNativeKey(DefaultFieldKeyMessage)
+	f.EncodeTypeString(e.Text)
 
 	// Time.
 	if !f.DisableFieldTime {
-		f.EncodeFieldTime(f.FieldKeyTime, e.Time)
+		f.addNativeKey(f.FieldKeyTime)
+		f.EncodeTypeTime(e.Time)
 	}
 
 	// Logger name.
 	if !f.DisableFieldName && e.LoggerName != "" {
-		f.EncodeFieldString(f.FieldKeyName, e.LoggerName)
+		f.addNativeKey(f.FieldKeyName)
+		f.EncodeTypeString(e.LoggerName)
 	}
 
 	// Caller.
 	if !f.DisableFieldCaller && e.Caller.Specified {
-		f.addKey(f.FieldKeyCaller)
+		f.addNativeKey(f.FieldKeyCaller)
 		f.EncodeCaller(e.Caller, f)
 	}
 
-	// Logger fields.
-	if bytes, ok := f.cache.Get(e.LoggerID); ok {
+	// CODE_FILE, CODE_LINE and CODE_FUNC.
+	if f.EnableFieldCode && e.Caller.Specified {
+		f.encodeCode(e.Caller)
+	}
+
+	// SYSLOG_IDENTIFIER, SYSLOG_FACILITY and SYSLOG_PID. They are encoded
+	// again if they do not fit MaxEntrySize.
+	if f.fitsEntry(len(f.syslog)) {
+		buf.AppendBytes(f.syslog)
+	} else {
+		f.encodeSyslog()
+	}
+
+	// Static fields. They are encoded again if they do not fit
+	// MaxEntrySize.
+	if f.fitsEntry(len(f.static)) {
+		buf.AppendBytes(f.static)
+	} else {
+		f.encodeStaticFields()
+	}
+
+	// Logger fields. Cached fields are encoded again if they do not fit
+	// MaxEntrySize.
+	cache := f.derivedCache(e.Level)
+	if bytes, ok := cache.Get(e.LoggerID); ok && f.fitsEntry(len(bytes)) {
 		buf.AppendBytes(bytes)
 	} else {
 		le := buf.Len()
+		f.entryLimited = false
 		for _, field := range e.DerivedFields {
 			field.Accept(f)
 		}
 
-		bf := make([]byte, buf.Len()-le)
-		copy(bf, buf.Data[le:])
-		f.cache.Set(e.LoggerID, bf)
+		// Do not cache fields of the entry that will be dropped or
+		// fields limited by the size of this entry.
+		if f.err == nil && !f.entryLimited {
+			bf := make([]byte, buf.Len()-le)
+			copy(bf, buf.Data[le:])
+			cache.Set(e.LoggerID, bf)
+		}
 	}
 
 	// Entry's fields.
@@ -112,11 +328,25 @@ func (f *encoder) Encode(buf *logf.Buffer, e logf.Entry) error {
 		field.Accept(f)
 	}
 
+	if f.err != nil {
+		// Drop the whole entry including the separator.
+		err := f.err
+		f.err = nil
+		buf.Data = buf.Data[:start]
+
+		return err
+	}
+
 	return nil
 }
 
 func (f *encoder) EncodeFieldAny(k string, v interface{}) {
-	f.addKey(k)
+	// MessageIDField is the only user field allowed to be native.
+	if _, ok := v.(MessageID); ok && k == DefaultFieldKeyMessageID {
+		f.addNativeKey(k)
+	} else {
+		f.addKey(k)
+	}
 	f.EncodeTypeAny(v)
 }
 
@@ -202,6 +432,11 @@ func (f *encoder) EncodeFieldArray(k string, v logf.ArrayEncoder) {
 }
 
 func (f *encoder) EncodeFieldObject(k string, v logf.ObjectEncoder) {
+	if f.FlattenObjects && f.depth < f.FlattenMaxDepth {
+		f.flattenObject(k, v)
+
+		return
+	}
 	f.addKey(k)
 	f.EncodeTypeObject(v)
 }
@@ -277,6 +512,13 @@ func (f *encoder) EncodeFieldDurations(k string, v []time.Duration) {
 }
 
 func (f *encoder) EncodeTypeAny(v interface{}) {
+	if id, ok := v.(MessageID); ok {
+		f.withValue(func() {
+			hex.Encode(f.buf.ExtendBytes(hex.EncodedLen(len(id))), id[:])
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeAny(v)
 	})
@@ -289,7 +531,7 @@ func (f *encoder) EncodeTypeByte(v byte) {
 }
 
 func (f *encoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
-	f.withValue(func() {
+	f.withTextValue(func() {
 		f.buf.AppendBytes(*(*[]byte)(v))
 	})
 }
@@ -301,7 +543,7 @@ func (f *encoder) EncodeTypeBool(v bool) {
 }
 
 func (f *encoder) EncodeTypeString(v string) {
-	f.withValue(func() {
+	f.withTextValue(func() {
 		f.buf.AppendString(v)
 	})
 }
@@ -374,90 +616,214 @@ func (f *encoder) EncodeTypeTime(v time.Time) {
 }
 
 func (f *encoder) EncodeTypeBytes(v []byte) {
-	f.withValue(func() {
-		base64.StdEncoding.Encode(f.buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+	f.withTextValue(func() {
+		f.EncodeBytes(f.buf, v)
 	})
 }
 
 func (f *encoder) EncodeTypeBools(v []bool) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeBool(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeBools(v)
 	})
 }
 
 func (f *encoder) EncodeTypeStrings(v []string) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeString(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeStrings(v)
 	})
 }
 
 func (f *encoder) EncodeTypeInts64(v []int64) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeInt64(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeInts64(v)
 	})
 }
 
 func (f *encoder) EncodeTypeInts32(v []int32) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeInt32(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeInts32(v)
 	})
 }
 
 func (f *encoder) EncodeTypeInts16(v []int16) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeInt16(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeInts16(v)
 	})
 }
 
 func (f *encoder) EncodeTypeInts8(v []int8) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeInt8(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeInts8(v)
 	})
 }
 
 func (f *encoder) EncodeTypeUints64(v []uint64) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeUint64(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeUints64(v)
 	})
 }
 
 func (f *encoder) EncodeTypeUints32(v []uint32) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeUint32(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeUints32(v)
 	})
 }
 
 func (f *encoder) EncodeTypeUints16(v []uint16) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeUint16(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeUints16(v)
 	})
 }
 
 func (f *encoder) EncodeTypeUints8(v []uint8) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeUint8(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeUints8(v)
 	})
 }
 
 func (f *encoder) EncodeTypeFloats64(v []float64) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeFloat64(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeFloats64(v)
 	})
 }
 
 func (f *encoder) EncodeTypeFloats32(v []float32) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeFloat32(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeFloats32(v)
 	})
 }
 
 func (f *encoder) EncodeTypeDurations(v []time.Duration) {
+	if f.repeatable() {
+		f.repeat(func() {
+			for i := range v {
+				f.EncodeTypeDuration(v[i])
+			}
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeDurations(v)
 	})
 }
 
 func (f *encoder) EncodeTypeArray(v logf.ArrayEncoder) {
+	if f.repeatable() {
+		f.repeat(func() {
+			_ = v.EncodeLogfArray(f)
+		})
+
+		return
+	}
 	f.withValue(func() {
 		f.mf.TypeEncoder(f.buf).EncodeTypeArray(v)
 	})
@@ -469,36 +835,411 @@ func (f *encoder) EncodeTypeObject(v logf.ObjectEncoder) {
 	})
 }
 
+func (f *encoder) encodeCode(c logf.EntryCaller) {
+	f.addNativeKey(DefaultFieldKeyCodeFile)
+	f.EncodeTypeString(c.File)
+	f.addNativeKey(DefaultFieldKeyCodeLine)
+	f.EncodeTypeInt64(int64(c.Line))
+	if c.PC != 0 {
+		if fn := runtime.FuncForPC(c.PC); fn != nil {
+			f.addNativeKey(DefaultFieldKeyCodeFunc)
+			f.EncodeTypeString(fn.Name())
+		}
+	}
+}
+
+// flattenObject encodes each field of the given object as a separate
+// field with the object key as a prefix.
+func (f *encoder) flattenObject(k string, v logf.ObjectEncoder) {
+	ki := f.userKey(k)
+
+	// Fields of the object are filtered and redacted the same way as the
+	// object encoded as a whole.
+	redactSubtree, includeSubtree := f.redactSubtree, f.includeSubtree
+	if f.filtering {
+		if f.excludeField(ki.filter) {
+			return
+		}
+		// Fields of the object that is not included still could be
+		// included by their own keys.
+		f.includeSubtree = includeSubtree || ki.filter.include
+	}
+	f.redactSubtree = redactSubtree || ki.redact
+
+	n := f.keyPrefix.Len()
+	f.keyPrefix.AppendBytes(ki.part)
+	f.keyPrefix.AppendBytes(f.flattenSeparator)
+	f.depth++
+
+	// Like the JSON encoder, ignore the error and keep fields encoded
+	// so far.
+	_ = v.EncodeLogfObject(f)
+
+	f.depth--
+	f.keyPrefix.Data = f.keyPrefix.Data[:n]
+	f.redactSubtree, f.includeSubtree = redactSubtree, includeSubtree
+}
+
 func (f *encoder) addKey(k string) {
+	ki := f.userKey(k)
+	f.keyStart = f.buf.Len()
+	f.buf.AppendBytes(ki.key)
+	f.keyEnd = f.buf.Len()
+	f.dropping = false
+	f.userField = true
+	f.redactKey = false
+
+	if f.filtering && f.dropField(ki.filter) {
+		f.dropping = true
+
+		return
+	}
+
+	if ki.reserved && f.CollisionPolicy != CollisionPolicyAllow {
+		f.resolveCollision(k)
+		// Affixes could make the key too long.
+		limitKey(f.buf, f.keyStart)
+		f.keyEnd = f.buf.Len()
+	}
+
+	f.redactKey = f.redactSubtree || ki.redact
+}
+
+// userKey returns keyInfo for the user field with the given key in the
+// current object being flattened. Results are cached.
+func (f *encoder) userKey(k string) keyInfo {
+	f.cacheKey.Reset()
+	f.cacheKey.AppendBytes(f.keyPrefix.Data)
+	f.cacheKey.AppendByte(0)
+	f.cacheKey.AppendString(k)
+	if ki, ok := f.keys.get(f.cacheKey.Data); ok {
+		return ki
+	}
+
+	buf := logf.NewBuffer()
+	if f.keyPrefix.Len() != 0 {
+		appendKeyPart(buf, k, f.NormalizeKey)
+	} else {
+		appendKey(buf, k, f.NormalizeKey)
+	}
+	part := buf.Len()
+	buf.AppendBytes(f.fieldKeyPrefix)
+	buf.AppendBytes(f.keyPrefix.Data)
+	buf.AppendBytes(buf.Data[:part])
+	// The prefix and flattened keys could make the key too long.
+	limitKey(buf, part)
+
+	ki := keyInfo{key: buf.Data[part:], part: buf.Data[:part]}
+	norm := string(ki.key)
+	// Rules are matched against the original key and the normalized one
+	// with and without FieldKeyPrefix.
+	keys := [...]string{k, string(f.keyPrefix.Data) + string(ki.part), norm}
+	_, ki.reserved = f.reserved[norm]
+	if f.filtering {
+		ki.filter = matchKeyFilter(keys[:], &f.EncoderConfig)
+	}
+	if f.redacting {
+		for _, key := range keys {
+			ki.redact = ki.redact || matchKeyPattern(key, f.RedactKeys, f.RedactKeyRegexps)
+		}
+	}
+	f.keys.set(f.cacheKey.Data, ki)
+
+	return ki
+}
+
+// dropField reports whether the field must be dropped according to
+// IncludeFields, ExcludeFields and FieldLevelRules.
+func (f *encoder) dropField(kf keyFilter) bool {
+	if len(f.IncludeFields) != 0 && !kf.include && !f.includeSubtree {
+		return true
+	}
+
+	return f.excludeField(kf)
+}
+
+// excludeField reports whether the field must be dropped according to
+// ExcludeFields and FieldLevelRules.
+func (f *encoder) excludeField(kf keyFilter) bool {
+	if kf.exclude {
+		return true
+	}
+	// Static fields do not depend on the entry.
+	return kf.rule >= 0 && !f.encodingStatic && !f.level.Enabled(f.FieldLevelRules[kf.rule].Level)
+}
+
+// derivedCache returns the cache of derived fields for the given level.
+// Derived fields depend on the level of the entry if there are
+// FieldLevelRules.
+func (f *encoder) derivedCache(lvl logf.Level) *logf.Cache {
+	if len(f.FieldLevelRules) == 0 {
+		return f.cache
+	}
+	c, ok := f.levelCaches[lvl]
+	if !ok {
+		if f.levelCaches == nil {
+			f.levelCaches = make(map[logf.Level]*logf.Cache)
+		}
+		c = logf.NewCache(derivedCacheSize)
+		f.levelCaches[lvl] = c
+	}
+
+	return c
+}
+
+// redactValue replaces the value started at the given position according
+// to RedactMode if it must be redacted.
+func (f *encoder) redactValue(pos int) {
+	if !f.userField || !f.redacting {
+		return
+	}
+	if !f.redactKey {
+		if !f.textValue {
+			return
+		}
+		v := f.buf.Data[pos:]
+		detected := false
+		for _, detect := range f.RedactValues {
+			if detect(v) {
+				detected = true
+
+				break
+			}
+		}
+		if !detected {
+			return
+		}
+	}
+
+	switch {
+	case f.mac != nil:
+		f.mac.Reset()
+		f.mac.Write(f.buf.Data[pos:])
+		f.macSum = f.mac.Sum(f.macSum[:0])
+		f.buf.Data = f.buf.Data[:pos]
+		f.buf.AppendString("hmac-sha256:")
+		hex.Encode(f.buf.ExtendBytes(hex.EncodedLen(len(f.macSum))), f.macSum)
+	default:
+		f.buf.Data = f.buf.Data[:pos]
+		f.buf.AppendString(f.RedactMask)
+	}
+}
+
+// addNativeKey adds the key of a field written by the Encoder itself.
+// Such keys are not checked for collisions.
+func (f *encoder) addNativeKey(k string) {
+	f.keyStart = f.buf.Len()
 	appendNormalizedKey(f.buf, k)
+	f.keyEnd = f.buf.Len()
+	f.dropping = false
+	f.userField, f.redactKey = false, false
+}
+
+// resolveCollision applies CollisionPolicy to the last added key that
+// collides with a reserved one.
+func (f *encoder) resolveCollision(k string) {
+	policy := f.CollisionPolicy
+	if policy == CollisionPolicyError && f.encodingStatic {
+		// There is no entry to drop.
+		policy = CollisionPolicyPrefix
+	}
+
+	switch policy {
+	case CollisionPolicyPrefix:
+		n := len(f.collisionAffix) + 1
+		f.buf.ExtendBytes(n)
+		copy(f.buf.Data[f.keyStart+n:], f.buf.Data[f.keyStart:f.keyEnd])
+		copy(f.buf.Data[f.keyStart:], f.collisionAffix)
+		f.buf.Data[f.keyStart+n-1] = '_'
+		f.keyEnd += n
+	case CollisionPolicySuffix:
+		f.buf.AppendByte('_')
+		f.buf.AppendBytes(f.collisionAffix)
+		f.keyEnd = f.buf.Len()
+	case CollisionPolicyError:
+		if f.err == nil {
+			f.err = &KeyCollisionError{Key: k, Field: string(f.buf.Data[f.keyStart:f.keyEnd])}
+		}
+		f.dropping = true
+	case CollisionPolicyDrop:
+		f.dropping = true
+	}
+}
+
+// repeatable reports whether a slice could be encoded as repeated fields.
+// It requires the key of the field to be added just before the value.
+// Nested slices are encoded as usual.
+func (f *encoder) repeatable() bool {
+	return f.RepeatSliceFields && !f.repeating && !f.dropping && f.keyEnd == f.buf.Len() && f.keyEnd != f.keyStart
+}
+
+// repeat encodes each value written by fn as a separate field with the
+// last added key. The key is removed if fn writes no values.
+func (f *encoder) repeat(fn func()) {
+	f.repeating, f.repeated = true, 0
+	fn()
+	f.repeating = false
+
+	if f.repeated == 0 {
+		f.buf.Data = f.buf.Data[:f.keyStart]
+	}
+}
+
+// repeatValue is withValue for an element of a slice being repeated.
+func (f *encoder) repeatValue(fn func()) {
+	if f.repeated != 0 {
+		// The key is dropped together with the previous element that
+		// does not fit MaxEntrySize. So do the rest.
+		if f.buf.Len() < f.keyEnd {
+			return
+		}
+		start := f.buf.Len()
+		f.buf.Data = append(f.buf.Data, f.buf.Data[f.keyStart:f.keyEnd]...)
+		f.keyStart, f.keyEnd = start, f.buf.Len()
+	}
+	f.repeated++
+
+	f.repeating = false
+	f.withValue(fn)
+	f.repeating = true
+}
+
+// withTextValue is like withValue but for string and bytes values.
+func (f *encoder) withTextValue(fn func()) {
+	f.textValue = true
+	f.withValue(fn)
+	f.textValue = false
 }
 
 func (f *encoder) withValue(fn func()) {
+	if f.repeating {
+		f.repeatValue(fn)
+
+		return
+	}
+
 	// According to the Encode, if the value includes a newline
 	// need to write the field name, plus a newline, then the
 	// size (64bit LE), the field data and a final newline.
 
 	f.buf.AppendByte('\n')
-	sizeBytes := f.buf.ExtendBytes(8)
+	f.buf.ExtendBytes(8)
 	pos := f.buf.Len()
 
 	fn()
 
-	binary.LittleEndian.PutUint64(sizeBytes, uint64(f.buf.Len()-pos))
+	if f.dropping {
+		f.buf.Data = f.buf.Data[:f.keyStart]
+		f.dropping = false
+
+		return
+	}
+
+	// Redact before truncation to not reveal the size of the secret.
+	f.redactValue(pos)
+
+	size := f.buf.Len() - pos
+	if limit := f.valueLimit(pos, size); limit < size {
+		if limit < 0 {
+			// Even the truncated value does not fit MaxEntrySize.
+			f.buf.Data = f.buf.Data[:f.keyStart]
+
+			return
+		}
+		f.truncateValue(pos, limit)
+	}
+
+	// The buffer could be reallocated by fn. Do not keep the size slice.
+	binary.LittleEndian.PutUint64(f.buf.Data[pos-8:pos], uint64(f.buf.Len()-pos))
 	f.buf.AppendByte('\n')
+
+	if size != f.buf.Len()-pos-1 {
+		f.appendTruncatedFrom(size)
+	}
 }
 
-func (f *encoder) levelToPriority(lvl logf.Level) journald.Priority {
-	switch lvl {
-	case logf.LevelDebug:
-		return journald.PriorityDebug
-	case logf.LevelInfo:
-		return journald.PriorityInfo
-	case logf.LevelWarn:
-		return journald.PriorityWarning
-	case logf.LevelError:
-		return journald.PriorityErr
-	default:
-		return journald.PriorityNotice
+// valueLimit returns the maximum size of the value of the given size
+// started at the given position according to MaxFieldSize and
+// MaxEntrySize. It returns a negative number if the value must be dropped.
+func (f *encoder) valueLimit(pos, size int) int {
+	limit := size
+	if f.MaxFieldSize > 0 && limit > f.MaxFieldSize {
+		limit = f.MaxFieldSize
+	}
+	if f.MaxEntrySize <= 0 || f.precomputing {
+		return limit
+	}
+
+	// The value is followed by a newline. A truncated value is followed
+	// by the companion field as well.
+	left := f.MaxEntrySize - (pos - f.entryStart) - 1
+	if limit < size {
+		left -= f.truncatedFromSize()
+	}
+	if limit <= left {
+		return limit
+	}
+
+	f.entryLimited = true
+	if limit == size {
+		left -= f.truncatedFromSize()
+	}
+	if left < 0 {
+		return -1
+	}
+
+	return left
+}
+
+// truncateValue truncates the value started at the given position to
+// the given limit on UTF-8 boundary and appends TruncationMarker.
+func (f *encoder) truncateValue(pos, limit int) {
+	marker := f.TruncationMarker
+	if len(marker) > limit {
+		marker = ""
+	}
+
+	end := pos + limit - len(marker)
+	for end > pos && !utf8.RuneStart(f.buf.Data[end]) {
+		end--
+	}
+	f.buf.Data = f.buf.Data[:end]
+	f.buf.AppendString(marker)
+}
+
+// truncatedFromSize returns the maximum size of the companion field
+// written by appendTruncatedFrom.
+func (f *encoder) truncatedFromSize() int {
+	keySize := f.keyEnd - f.keyStart + len(truncatedFromSuffix)
+	if keySize > maxFieldKeyLen {
+		keySize = maxFieldKeyLen
 	}
+
+	// Key, newline, 64-bit size, up to 20 digits and a newline.
+	return keySize + 1 + 8 + 20 + 1
+}
+
+// appendTruncatedFrom appends the companion field with the original size
+// of the truncated value of the last added key. The key is cut to keep
+// the suffix of the companion field name within the journal limit.
+func (f *encoder) appendTruncatedFrom(size int) {
+	key := f.buf.Data[f.keyStart:f.keyEnd]
+	if len(key) > maxFieldKeyLen-len(truncatedFromSuffix) {
+		key = key[:maxFieldKeyLen-len(truncatedFromSuffix)]
+	}
+	f.buf.Data = append(f.buf.Data, key...)
+	f.buf.AppendString(truncatedFromSuffix)
+
+	f.buf.AppendByte('\n')
+	f.buf.ExtendBytes(8)
+	pos := f.buf.Len()
+	logf.AppendInt(f.buf, int64(size))
+	binary.LittleEndian.PutUint64(f.buf.Data[pos-8:pos], uint64(f.buf.Len()-pos))
+	f.buf.AppendByte('\n')
+}
+
+// fitsEntry reports whether the given number of bytes could be appended
+// to the entry without exceeding MaxEntrySize.
+func (f *encoder) fitsEntry(n int) bool {
+	return f.MaxEntrySize <= 0 || f.buf.Len()-f.entryStart+n <= f.MaxEntrySize
 }
@@ -1,6 +1,12 @@
 package logfjournald
 
-import "github.com/ssgreg/logf"
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/ssgreg/logf"
+)
 
 // Default field keys.
 const (
@@ -10,8 +16,34 @@ const (
 	DefaultFieldKeyCaller = "CALLER"
 
 	// Systemd journal dependent field keys.
-	DefaultFieldKeyPriority = "PRIORITY"
-	DefaultFieldKeyMessage  = "MESSAGE"
+	DefaultFieldKeyPriority  = "PRIORITY"
+	DefaultFieldKeyMessage   = "MESSAGE"
+	DefaultFieldKeyMessageID = "MESSAGE_ID"
+	DefaultFieldKeyCodeFile  = "CODE_FILE"
+	DefaultFieldKeyCodeLine  = "CODE_LINE"
+	DefaultFieldKeyCodeFunc  = "CODE_FUNC"
+
+	DefaultFieldKeySyslogIdentifier = "SYSLOG_IDENTIFIER"
+	DefaultFieldKeySyslogFacility   = "SYSLOG_FACILITY"
+	DefaultFieldKeySyslogPID        = "SYSLOG_PID"
+)
+
+// DefaultTruncationMarker is appended to values truncated because of
+// MaxFieldSize or MaxEntrySize.
+const DefaultTruncationMarker = "..."
+
+// truncatedFromSuffix is the suffix of the companion field holding the
+// original size of the truncated value.
+const truncatedFromSuffix = "_TRUNCATED_FROM"
+
+// DefaultCollisionAffix is the default affix for keys of user fields that
+// collide with reserved fields.
+const DefaultCollisionAffix = "LOGF"
+
+// Default values for flattening of nested objects.
+const (
+	DefaultFlattenSeparator = "_"
+	DefaultFlattenMaxDepth  = 8
 )
 
 // EncoderConfig allows to configure journal Encoder.
@@ -45,11 +77,159 @@ type EncoderConfig struct {
 	// DisableFieldCaller disables the caller field.
 	DisableFieldCaller bool
 
+	// EnableFieldCode enables the native journal's CODE_FILE, CODE_LINE
+	// and CODE_FUNC fields. Use it together with DisableFieldCaller to
+	// replace the caller field with the native ones.
+	EnableFieldCode bool
+
+	// SyslogIdentifier is the value of the native journal's
+	// SYSLOG_IDENTIFIER field that allows to filter entries with
+	// journalctl -t. Default is the base name of os.Args[0].
+	SyslogIdentifier string
+
+	// DisableFieldSyslogIdentifier disables the SYSLOG_IDENTIFIER field.
+	DisableFieldSyslogIdentifier bool
+
+	// SyslogFacility is the value of the native journal's
+	// SYSLOG_FACILITY field. The field is written only if the facility
+	// is specified.
+	SyslogFacility Facility
+
+	// SyslogPID is the value of the native journal's SYSLOG_PID field.
+	// Default is the process ID of the caller.
+	SyslogPID int
+
+	// EnableFieldSyslogPID enables the SYSLOG_PID field.
+	EnableFieldSyslogPID bool
+
+	// FlattenObjects enables expanding of nested objects into separate
+	// fields, e.g. USER={"name":"x"} becomes USER_NAME=x. That allows to
+	// filter entries with journalctl USER_NAME=x.
+	FlattenObjects bool
+
+	// FlattenSeparator separates keys of nested objects. It is normalized
+	// the same way as keys. Default is "_".
+	FlattenSeparator string
+
+	// FlattenMaxDepth limits the number of flattened nesting levels.
+	// Objects nested deeper are encoded as a whole with the
+	// TypeEncoderFactory. Default is 8.
+	FlattenMaxDepth int
+
+	// RepeatSliceFields enables encoding of slices such as logf.Strings
+	// as repeated fields with the same key, one per element. The journal
+	// allows a field to occur multiple times in one entry and journalctl
+	// FIELD=x matches any of them. An empty slice produces no field.
+	// Nested slices are encoded with the TypeEncoderFactory.
+	RepeatSliceFields bool
+
+	// FieldKeyPrefix is prepended to keys of user fields including
+	// derived and flattened ones, e.g. APP makes user.id become
+	// APP_USER_ID. The fields written by the Encoder itself are not
+	// prefixed. It is normalized the same way as keys.
+	FieldKeyPrefix string
+
+	// NormalizeKey converts keys of user fields to journal field names.
+	// The fields written by the Encoder itself such as MESSAGE or
+	// FieldKeyLevel are always normalized with DefaultKeyNormalizer.
+	// Default is DefaultKeyNormalizer.
+	NormalizeKey KeyNormalizer
+
+	// CollisionPolicy defines how to handle user fields that collide
+	// with reserved fields after normalization. Reserved fields are the
+	// systemd journal well-known fields (see IsReservedFieldKey) and
+	// enabled fields of the Encoder such as FieldKeyLevel. Default is
+	// CollisionPolicyPrefix.
+	CollisionPolicy CollisionPolicy
+
+	// CollisionAffix is added to keys of colliding fields by
+	// CollisionPolicyPrefix and CollisionPolicySuffix. It is normalized
+	// the same way as keys. Default is "LOGF".
+	CollisionAffix string
+
+	// MaxFieldSize limits the size of a field value in bytes. Longer
+	// values are truncated on UTF-8 boundary and end with
+	// TruncationMarker. The original size is written to the companion
+	// <KEY>_TRUNCATED_FROM field. Zero means no limit.
+	MaxFieldSize int
+
+	// MaxEntrySize limits the size of an encoded entry in bytes. The
+	// value that does not fit is truncated the same way as with
+	// MaxFieldSize, fields that do not fit even truncated are dropped.
+	// Fields are written in order, so the fields written by the Encoder
+	// itself such as MESSAGE take precedence over user fields. Zero means
+	// no limit.
+	MaxEntrySize int
+
+	// TruncationMarker is appended to truncated values. Default is "...".
+	TruncationMarker string
+
+	// StaticFields are appended to every entry after the fields written by
+	// the Encoder itself, e.g. logf.String("version", version). They are
+	// encoded once when the Encoder is created, so all the rules for user
+	// fields except FieldLevelRules apply to them. With
+	// CollisionPolicyError colliding static fields are prefixed instead.
+	StaticFields []logf.Field
+
+	// IncludeFields holds glob patterns in path.Match syntax. If it is
+	// not empty, only user fields with original or normalized keys
+	// matching any of them are written. Keys of flattened objects are
+	// matched as a whole, e.g. "HTTP_*" matches all fields of the http
+	// object. Rules for the key of an object apply to all of its fields
+	// the same way with and without FlattenObjects. Normalized keys are
+	// matched with and without FieldKeyPrefix.
+	IncludeFields []string
+
+	// ExcludeFields holds glob patterns in path.Match syntax. User fields
+	// with original or normalized keys matching any of them are dropped.
+	// It takes precedence over IncludeFields.
+	ExcludeFields []string
+
+	// FieldLevelRules restrict user fields to entries of specific levels.
+	// The first rule matching the field is applied. Derived fields are
+	// cached for each level of entries if there are any rules.
+	FieldLevelRules []FieldLevelRule
+
+	// RedactKeys holds glob patterns in path.Match syntax. Values of user
+	// fields with original or normalized keys matching any of them are
+	// redacted, e.g. "*password*" or "*AUTHORIZATION". Keys of flattened
+	// objects are matched as well, e.g. "password" and "USER_PASSWORD"
+	// both match the password field of the user object. Normalized keys
+	// are matched with and without FieldKeyPrefix.
+	RedactKeys []string
+
+	// RedactKeyRegexps is like RedactKeys but holds regular expressions.
+	RedactKeyRegexps []*regexp.Regexp
+
+	// RedactValues holds detectors of sensitive values of user fields,
+	// e.g. DetectJWT and DetectCreditCard. Only string and bytes values
+	// are checked.
+	RedactValues []ValueDetector
+
+	// RedactMode defines how redacted values are replaced. Default is
+	// RedactModeMask.
+	RedactMode RedactMode
+
+	// RedactHashKey is the secret key of HMAC used with RedactModeHash.
+	// It must be kept secret from readers of the journal.
+	RedactHashKey []byte
+
+	// RedactMask replaces redacted values with RedactModeMask. Default is
+	// "[REDACTED]".
+	RedactMask string
+
 	EncodeTime     logf.TimeEncoder
 	EncodeDuration logf.DurationEncoder
 	EncodeError    logf.ErrorEncoder
 	EncodeLevel    logf.LevelEncoder
 	EncodeCaller   logf.CallerEncoder
+
+	// EncodeLevelPriority maps severity levels to the journal's PRIORITY.
+	EncodeLevelPriority LevelPriorityEncoder
+
+	// EncodeBytes encodes byte slices such as logf.ConstBytes. Default is
+	// Base64BytesEncoder.
+	EncodeBytes BytesEncoder
 }
 
 // WithDefaults returns the new config in which all uninitialized fields are
@@ -69,6 +249,42 @@ func (c EncoderConfig) WithDefaults() EncoderConfig {
 		c.FieldKeyCaller = DefaultFieldKeyCaller
 	}
 
+	// Handle defaults for syslog fields.
+	if c.SyslogIdentifier == "" && len(os.Args) != 0 {
+		c.SyslogIdentifier = filepath.Base(os.Args[0])
+	}
+	if c.SyslogPID == 0 {
+		c.SyslogPID = os.Getpid()
+	}
+
+	// Handle default for key normalization.
+	if c.NormalizeKey == nil {
+		c.NormalizeKey = DefaultKeyNormalizer
+	}
+
+	// Handle defaults for collisions.
+	if c.CollisionAffix == "" {
+		c.CollisionAffix = DefaultCollisionAffix
+	}
+
+	// Handle default for redaction.
+	if c.RedactMask == "" {
+		c.RedactMask = DefaultRedactMask
+	}
+
+	// Handle default for truncation.
+	if c.TruncationMarker == "" {
+		c.TruncationMarker = DefaultTruncationMarker
+	}
+
+	// Handle defaults for flattening.
+	if c.FlattenSeparator == "" {
+		c.FlattenSeparator = DefaultFlattenSeparator
+	}
+	if c.FlattenMaxDepth == 0 {
+		c.FlattenMaxDepth = DefaultFlattenMaxDepth
+	}
+
 	// Handle defaults for type encoder.
 	if c.EncodeDuration == nil {
 		c.EncodeDuration = logf.StringDurationEncoder
@@ -85,6 +301,12 @@ func (c EncoderConfig) WithDefaults() EncoderConfig {
 	if c.EncodeCaller == nil {
 		c.EncodeCaller = logf.ShortCallerEncoder
 	}
+	if c.EncodeLevelPriority == nil {
+		c.EncodeLevelPriority = DefaultLevelPriorityEncoder
+	}
+	if c.EncodeBytes == nil {
+		c.EncodeBytes = Base64BytesEncoder
+	}
 
 	return c
 }
@@ -4,6 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +15,15 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// newTestEncoder creates the new Encoder with the given config. The
+// Encoder writes no SYSLOG_IDENTIFIER field because it depends on the
+// test binary name.
+func newTestEncoder(c EncoderConfig) logf.Encoder {
+	c.DisableFieldSyslogIdentifier = true
+
+	return NewEncoder(c, logf.NewJSONTypeEncoderFactory.Default())
+}
+
 type encoderTestCase struct {
 	Name   string
 	Entry  []logf.Entry
@@ -56,6 +69,20 @@ func (u users) EncodeLogfArray(enc logf.TypeEncoder) error {
 	return nil
 }
 
+type account struct {
+	ID    int64
+	Owner *user
+	Tags  []string
+}
+
+func (a *account) EncodeLogfObject(enc logf.FieldEncoder) error {
+	enc.EncodeFieldInt64("id", a.ID)
+	enc.EncodeFieldObject("owner", a.Owner)
+	enc.EncodeFieldStrings("tags", a.Tags)
+
+	return nil
+}
+
 type MyInt int
 type MyUint uint
 type MyBool bool
@@ -386,7 +413,7 @@ func TestEncoder(t *testing.T) {
 		},
 	}
 
-	enc := NewEncoder.Default()
+	enc := newTestEncoder(EncoderConfig{})
 
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -399,3 +426,405 @@ func TestEncoder(t *testing.T) {
 		})
 	}
 }
+
+func TestEncoderCode(t *testing.T) {
+	caller := logf.NewEntryCaller(0)
+	entry := logf.Entry{Level: logf.LevelInfo, Text: "m", Caller: caller}
+
+	t.Run("InAdditionToCaller", func(t *testing.T) {
+		fields := encodeTestEntry(t, NewEncoder(EncoderConfig{EnableFieldCode: true}, logf.NewJSONTypeEncoderFactory.Default()), entry)
+
+		require.Equal(t, []string{caller.File}, fields["CODE_FILE"])
+		require.Equal(t, []string{strconv.Itoa(caller.Line)}, fields["CODE_LINE"])
+		require.Equal(t, []string{"github.com/ssgreg/logfjournald.TestEncoderCode"}, fields["CODE_FUNC"])
+		require.Contains(t, fields, "CALLER")
+	})
+
+	t.Run("InsteadOfCaller", func(t *testing.T) {
+		fields := encodeTestEntry(t, NewEncoder(EncoderConfig{EnableFieldCode: true, DisableFieldCaller: true}, logf.NewJSONTypeEncoderFactory.Default()), entry)
+
+		require.Equal(t, []string{caller.File}, fields["CODE_FILE"])
+		require.NotContains(t, fields, "CALLER")
+	})
+
+	t.Run("NoPC", func(t *testing.T) {
+		e := entry
+		e.Caller.PC = 0
+		fields := encodeTestEntry(t, NewEncoder(EncoderConfig{EnableFieldCode: true}, logf.NewJSONTypeEncoderFactory.Default()), e)
+
+		require.Contains(t, fields, "CODE_FILE")
+		require.Contains(t, fields, "CODE_LINE")
+		require.NotContains(t, fields, "CODE_FUNC")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		fields := encodeTestEntry(t, NewEncoder.Default(), entry)
+
+		require.NotContains(t, fields, "CODE_FILE")
+	})
+}
+
+func TestEncoderSyslog(t *testing.T) {
+	entry := logf.Entry{Level: logf.LevelInfo, Text: "m"}
+
+	encode := func(c EncoderConfig) map[string][]string {
+		return encodeTestEntry(t, NewEncoder(c, logf.NewJSONTypeEncoderFactory.Default()), entry)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		fields := encode(EncoderConfig{})
+		require.Equal(t, []string{filepath.Base(os.Args[0])}, fields["SYSLOG_IDENTIFIER"])
+		require.NotContains(t, fields, "SYSLOG_FACILITY")
+		require.NotContains(t, fields, "SYSLOG_PID")
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		fields := encode(EncoderConfig{
+			SyslogIdentifier:     "myservice",
+			SyslogFacility:       FacilityLocal3,
+			EnableFieldSyslogPID: true,
+		})
+		require.Equal(t, []string{"myservice"}, fields["SYSLOG_IDENTIFIER"])
+		require.Equal(t, []string{"19"}, fields["SYSLOG_FACILITY"])
+		require.Equal(t, []string{strconv.Itoa(os.Getpid())}, fields["SYSLOG_PID"])
+
+		fields = encode(EncoderConfig{SyslogPID: 1, EnableFieldSyslogPID: true})
+		require.Equal(t, []string{"1"}, fields["SYSLOG_PID"])
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		fields := encode(EncoderConfig{DisableFieldSyslogIdentifier: true})
+		require.NotContains(t, fields, "SYSLOG_IDENTIFIER")
+	})
+
+	t.Run("NoAllocs", func(t *testing.T) {
+		requireNoAllocs(t, NewEncoder(EncoderConfig{
+			SyslogFacility:       FacilityUser,
+			EnableFieldSyslogPID: true,
+		}, logf.NewJSONTypeEncoderFactory.Default()), entry)
+	})
+}
+
+func TestEncoderStaticFields(t *testing.T) {
+	c := EncoderConfig{
+		FieldKeyPrefix: "app",
+		RedactKeys:     []string{"token"},
+		ExcludeFields:  []string{"debug"},
+		StaticFields: []logf.Field{
+			logf.String("version", "1.2.3"),
+			logf.String("git.sha", "abc"),
+			logf.String("token", "secret"),
+			logf.String("debug", "x"),
+			logf.String("message", "m"),
+		},
+		FieldLevelRules: []FieldLevelRule{{Keys: []string{"version"}, Level: logf.LevelDebug}},
+	}
+	entry := logf.Entry{LoggerID: 1, Level: logf.LevelInfo, Text: "m", DerivedFields: []logf.Field{logf.String("service", "api")}}
+
+	t.Run("Default", func(t *testing.T) {
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"APP_VERSION", []byte("1.2.3")},
+			{"APP_GIT_SHA", []byte("abc")},
+			{"APP_TOKEN", []byte("[REDACTED]")},
+			{"APP_MESSAGE", []byte("m")},
+			{"APP_SERVICE", []byte("api")},
+		}, encodeTestFields(t, newTestEncoder(c), entry))
+	})
+
+	t.Run("CollisionPolicyError", func(t *testing.T) {
+		c := EncoderConfig{
+			CollisionPolicy: CollisionPolicyError,
+			StaticFields:    []logf.Field{logf.String("message", "m"), logf.String("version", "1.2.3")},
+		}
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"LOGF_MESSAGE", []byte("m")},
+			{"VERSION", []byte("1.2.3")},
+		}, encodeTestFields(t, newTestEncoder(c), logf.Entry{Level: logf.LevelInfo, Text: "m"}))
+
+		// Colliding entry fields still fail.
+		err := newTestEncoder(c).Encode(logf.NewBuffer(), logf.Entry{Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{logf.String("message", "x")}})
+		require.IsType(t, &KeyCollisionError{}, err)
+	})
+
+	t.Run("MaxEntrySize", func(t *testing.T) {
+		body := strings.Repeat("b", 100)
+		enc := newTestEncoder(EncoderConfig{MaxEntrySize: 300, StaticFields: []logf.Field{logf.String("static", body)}})
+		require.Equal(t, []string{body}, encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: "m"})["STATIC"])
+
+		// Static fields do not fit and are encoded again.
+		fields := encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: body})
+		require.Equal(t, []string{"100"}, fields["STATIC_TRUNCATED_FROM"])
+	})
+
+	t.Run("NoAllocs", func(t *testing.T) {
+		requireNoAllocs(t, newTestEncoder(c), entry)
+	})
+}
+
+func TestEncoderFlatten(t *testing.T) {
+	acc := &account{ID: 42, Owner: &user{Name: "x"}, Tags: []string{"a", "b"}}
+	entry := logf.Entry{
+		LoggerID:      1,
+		Level:         logf.LevelInfo,
+		Text:          "m",
+		DerivedFields: []logf.Field{logf.Object("derived", &user{Name: "d"})},
+		Fields:        []logf.Field{logf.Object("account", acc), logf.String("after", "v")},
+	}
+
+	encode := func(c EncoderConfig) map[string][]string {
+		return encodeTestEntry(t, newTestEncoder(c), entry)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		fields := encode(EncoderConfig{})
+		require.Equal(t, []string{`{"id":42,"owner":{"name":"x"},"tags":["a","b"]}`}, fields["ACCOUNT"])
+		require.Equal(t, []string{`{"name":"d"}`}, fields["DERIVED"])
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		fields := encode(EncoderConfig{FlattenObjects: true})
+		require.NotContains(t, fields, "ACCOUNT")
+		require.Equal(t, []string{"42"}, fields["ACCOUNT_ID"])
+		require.Equal(t, []string{"x"}, fields["ACCOUNT_OWNER_NAME"])
+		require.Equal(t, []string{`["a","b"]`}, fields["ACCOUNT_TAGS"])
+		require.Equal(t, []string{"d"}, fields["DERIVED_NAME"])
+		require.Equal(t, []string{"v"}, fields["AFTER"])
+	})
+
+	t.Run("Separator", func(t *testing.T) {
+		fields := encode(EncoderConfig{FlattenObjects: true, FlattenSeparator: ".."})
+		require.Equal(t, []string{"x"}, fields["ACCOUNT__OWNER__NAME"])
+	})
+
+	t.Run("MaxDepth", func(t *testing.T) {
+		fields := encode(EncoderConfig{FlattenObjects: true, FlattenMaxDepth: 1})
+		require.Equal(t, []string{"42"}, fields["ACCOUNT_ID"])
+		require.Equal(t, []string{`{"name":"x"}`}, fields["ACCOUNT_OWNER"])
+	})
+
+	t.Run("NoAllocs", func(t *testing.T) {
+		e := logf.Entry{Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{logf.Object("owner", acc.Owner)}}
+		requireNoAllocs(t, newTestEncoder(EncoderConfig{FlattenObjects: true}), e)
+	})
+}
+
+func TestEncoderBufferGrowth(t *testing.T) {
+	value := string(make([]byte, 10000))
+	b := &logf.Buffer{}
+	require.NoError(t, newTestEncoder(EncoderConfig{}).Encode(b, logf.Entry{
+		Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{logf.String("big", value)},
+	}))
+
+	require.Equal(t, value, decodeTestEntry(t, b.Bytes())["BIG"])
+}
+
+func TestEncoderRepeatSliceFields(t *testing.T) {
+	entry := logf.Entry{
+		Level: logf.LevelInfo,
+		Text:  "m",
+		Fields: []logf.Field{
+			logf.Strings("tags", []string{"a", "b"}),
+			logf.ConstInts("ids", []int{1, 2, 3}),
+			logf.ConstDurations("durations", []time.Duration{time.Second}),
+			logf.ConstInts("noints", nil),
+			logf.Strings("empty", nil),
+			logf.Object("account", &account{ID: 1, Owner: &user{}, Tags: []string{"x", "y"}}),
+		},
+	}
+
+	encode := func(c EncoderConfig) map[string][]string {
+		return encodeTestEntry(t, newTestEncoder(c), entry)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		fields := encode(EncoderConfig{})
+		require.Equal(t, []string{`["a","b"]`}, fields["TAGS"])
+		require.Equal(t, []string{`[]`}, fields["EMPTY"])
+		require.Equal(t, []string{`[1,2,3]`}, fields["IDS"])
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		fields := encode(EncoderConfig{RepeatSliceFields: true, FlattenObjects: true})
+		require.Equal(t, []string{"a", "b"}, fields["TAGS"])
+		require.Equal(t, []string{"1", "2", "3"}, fields["IDS"])
+		require.Equal(t, []string{"1s"}, fields["DURATIONS"])
+		require.NotContains(t, fields, "EMPTY")
+		require.NotContains(t, fields, "NOINTS")
+		require.Equal(t, []string{"x", "y"}, fields["ACCOUNT_TAGS"])
+	})
+
+	t.Run("NoAllocs", func(t *testing.T) {
+		e := logf.Entry{Level: logf.LevelInfo, Text: "m", Fields: entry.Fields[:2]}
+		requireNoAllocs(t, newTestEncoder(EncoderConfig{RepeatSliceFields: true}), e)
+	})
+}
+
+func TestEncoderFieldKeyPrefix(t *testing.T) {
+	enc := newTestEncoder(EncoderConfig{FieldKeyPrefix: "app", FlattenObjects: true, EnableFieldCode: true})
+	e := logf.Entry{
+		LoggerID:      1,
+		LoggerName:    "logger",
+		Level:         logf.LevelInfo,
+		Text:          "m",
+		Caller:        logf.NewEntryCaller(0),
+		DerivedFields: []logf.Field{logf.String("derived", "d")},
+		Fields: []logf.Field{
+			logf.String("user_id", "1"),
+			logf.String("message", "user message"),
+			logf.Object("user", &user{Name: "x"}),
+			MessageIDField(MustParseMessageID(testMessageID)),
+		},
+	}
+
+	fields := encodeTestEntry(t, enc, e)
+	require.Equal(t, []string{"1"}, fields["APP_USER_ID"])
+	require.Equal(t, []string{"user message"}, fields["APP_MESSAGE"])
+	require.Equal(t, []string{"x"}, fields["APP_USER_NAME"])
+	require.Equal(t, []string{"d"}, fields["APP_DERIVED"])
+	require.Equal(t, []string{"m"}, fields["MESSAGE"])
+	require.Equal(t, []string{testMessageID}, fields["MESSAGE_ID"])
+	for _, k := range []string{"PRIORITY", "LEVEL", "TS", "LOGGER", "CALLER", "CODE_FILE", "CODE_LINE", "CODE_FUNC"} {
+		require.Contains(t, fields, k)
+	}
+
+	// The prefix has a single trailing underscore.
+	fields = encodeTestEntry(t, newTestEncoder(EncoderConfig{FieldKeyPrefix: "APP_"}), logf.Entry{
+		Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{logf.String("id", "1")},
+	})
+	require.Contains(t, fields, "APP_ID")
+}
+
+func TestEncoderMaxFieldSize(t *testing.T) {
+	encode := func(c EncoderConfig, fields ...logf.Field) map[string][]string {
+		return encodeTestEntry(t, newTestEncoder(c), logf.Entry{Level: logf.LevelInfo, Text: "a long message", Fields: fields})
+	}
+
+	t.Run("Truncated", func(t *testing.T) {
+		fields := encode(EncoderConfig{MaxFieldSize: 8}, logf.String("body", "hello world"), logf.String("short", "12345678"))
+		require.Equal(t, []string{"hello..."}, fields["BODY"])
+		require.Equal(t, []string{"11"}, fields["BODY_TRUNCATED_FROM"])
+		require.Equal(t, []string{"12345678"}, fields["SHORT"])
+		require.NotContains(t, fields, "SHORT_TRUNCATED_FROM")
+		require.Equal(t, []string{"a lon..."}, fields["MESSAGE"])
+		require.Equal(t, []string{"14"}, fields["MESSAGE_TRUNCATED_FROM"])
+	})
+
+	t.Run("UTF8", func(t *testing.T) {
+		fields := encode(EncoderConfig{MaxFieldSize: 6}, logf.String("body", "ééééé"))
+		require.Equal(t, []string{"é..."}, fields["BODY"])
+		require.Equal(t, []string{"10"}, fields["BODY_TRUNCATED_FROM"])
+	})
+
+	t.Run("Marker", func(t *testing.T) {
+		fields := encode(EncoderConfig{MaxFieldSize: 4, TruncationMarker: "~"}, logf.String("body", "hello"))
+		require.Equal(t, []string{"hel~"}, fields["BODY"])
+
+		fields = encode(EncoderConfig{MaxFieldSize: 2}, logf.String("body", "hello"))
+		require.Equal(t, []string{"he"}, fields["BODY"])
+	})
+
+	t.Run("LongKey", func(t *testing.T) {
+		key := strings.Repeat("k", 60)
+		fields := encode(EncoderConfig{MaxFieldSize: 4}, logf.String(key, "hello"))
+		require.Equal(t, []string{"h..."}, fields[strings.ToUpper(key)])
+		// The key is cut to keep the suffix of the companion field.
+		require.Equal(t, []string{"5"}, fields[strings.Repeat("K", 49)+"_TRUNCATED_FROM"])
+	})
+
+	t.Run("Repeated", func(t *testing.T) {
+		fields := encodeTestFields(t, newTestEncoder(EncoderConfig{MaxFieldSize: 4, RepeatSliceFields: true}), logf.Entry{
+			Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{logf.Strings("tags", []string{"a", "bbbbbb"})},
+		})
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0...")},
+			{"TS_TRUNCATED_FROM", []byte("20")},
+			{"TAGS", []byte("a")},
+			{"TAGS", []byte("b...")},
+			{"TAGS_TRUNCATED_FROM", []byte("6")},
+		}, fields)
+	})
+}
+
+func TestEncoderMaxEntrySize(t *testing.T) {
+	const maxEntrySize = 300
+	body := strings.Repeat("b", 100)
+
+	t.Run("Fields", func(t *testing.T) {
+		enc := newTestEncoder(EncoderConfig{MaxEntrySize: maxEntrySize})
+		fields := encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{
+			logf.String("first", body),
+			logf.String("second", body),
+			logf.String("third", body),
+		}})
+		require.Equal(t, []string{"m"}, fields["MESSAGE"])
+		require.Equal(t, []string{body}, fields["FIRST"])
+		require.Len(t, fields["SECOND"], 1)
+		require.True(t, strings.HasSuffix(fields["SECOND"][0], "..."))
+		require.Equal(t, []string{"100"}, fields["SECOND_TRUNCATED_FROM"])
+		require.NotContains(t, fields, "THIRD")
+	})
+
+	t.Run("Message", func(t *testing.T) {
+		enc := newTestEncoder(EncoderConfig{MaxEntrySize: maxEntrySize})
+		fields := encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: strings.Repeat("m", 1000), Fields: []logf.Field{
+			logf.String("field", body),
+		}})
+		require.Len(t, fields["MESSAGE"], 1)
+		require.True(t, strings.HasPrefix(fields["MESSAGE"][0], "mmm"))
+		require.Equal(t, []string{"1000"}, fields["MESSAGE_TRUNCATED_FROM"])
+	})
+
+	t.Run("Repeated", func(t *testing.T) {
+		enc := newTestEncoder(EncoderConfig{MaxEntrySize: maxEntrySize, RepeatSliceFields: true})
+		fields := encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{
+			logf.Strings("tags", []string{body, body, body, body}),
+			logf.String("after", "v"),
+		}})
+		require.Equal(t, []string{"100"}, fields["TAGS_TRUNCATED_FROM"])
+	})
+
+	t.Run("Syslog", func(t *testing.T) {
+		enc := NewEncoder(EncoderConfig{MaxEntrySize: maxEntrySize}, logf.NewJSONTypeEncoderFactory.Default())
+		fields := encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: "m"})
+		require.Equal(t, []string{filepath.Base(os.Args[0])}, fields["SYSLOG_IDENTIFIER"])
+
+		// SYSLOG_* fields do not fit after the long message.
+		fields = encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: strings.Repeat("m", maxEntrySize)})
+		require.Contains(t, fields, "MESSAGE_TRUNCATED_FROM")
+		require.NotContains(t, fields, "SYSLOG_IDENTIFIER")
+
+		// SYSLOG_* fields larger than MaxEntrySize itself are not dropped
+		// once for all entries.
+		enc = NewEncoder(EncoderConfig{MaxEntrySize: maxEntrySize, SyslogIdentifier: body + body + body}, logf.NewJSONTypeEncoderFactory.Default())
+		fields = encodeTestEntry(t, enc, logf.Entry{Level: logf.LevelInfo, Text: "m"})
+		require.Len(t, fields["SYSLOG_IDENTIFIER"], 1)
+		require.True(t, strings.HasPrefix(fields["SYSLOG_IDENTIFIER"][0], body))
+		require.Equal(t, []string{"300"}, fields["SYSLOG_IDENTIFIER_TRUNCATED_FROM"])
+	})
+
+	t.Run("DerivedFields", func(t *testing.T) {
+		enc := newTestEncoder(EncoderConfig{MaxEntrySize: maxEntrySize})
+		e := logf.Entry{LoggerID: 1, Level: logf.LevelInfo, Text: "m", DerivedFields: []logf.Field{logf.String("derived", body)}}
+
+		require.Equal(t, []string{body}, encodeTestEntry(t, enc, e)["DERIVED"])
+
+		// Cached derived fields do not fit.
+		long := e
+		long.Text = body
+		require.Contains(t, encodeTestEntry(t, enc, long), "DERIVED_TRUNCATED_FROM")
+
+		// Truncated derived fields are not cached.
+		require.Equal(t, []string{body}, encodeTestEntry(t, enc, e)["DERIVED"])
+	})
+}
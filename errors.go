@@ -0,0 +1,92 @@
+package logfjournald
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EntryError describes a failure to write a single entry to the journal.
+type EntryError struct {
+	// Index is the position of the entry in the flushed batch.
+	Index int
+	Err   error
+}
+
+// Error implements error.
+func (e EntryError) Error() string {
+	return "entry " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e EntryError) Unwrap() error {
+	return e.Err
+}
+
+// FlushError is returned by Appender's Flush and Sync when some of the
+// pending entries were not written to the journal. The rest of entries
+// are written successfully.
+type FlushError struct {
+	// Namespace is the journal namespace the entries were written to.
+	// Empty for the default namespace.
+	Namespace string
+
+	// SocketPath is the path of the journal socket the entries were
+	// written to.
+	SocketPath string
+
+	// Total is the number of entries in the flushed batch.
+	Total   int
+	Entries []EntryError
+}
+
+// Error implements error.
+func (e *FlushError) Error() string {
+	var b strings.Builder
+	b.WriteString("logfjournald: failed to write ")
+	b.WriteString(strconv.Itoa(len(e.Entries)))
+	b.WriteString(" of ")
+	b.WriteString(strconv.Itoa(e.Total))
+	b.WriteString(" entries")
+	if e.Namespace != "" {
+		b.WriteString(" to namespace ")
+		b.WriteString(strconv.Quote(e.Namespace))
+	}
+	if e.SocketPath != "" {
+		b.WriteString(" via ")
+		b.WriteString(e.SocketPath)
+	}
+	for i, ee := range e.Entries {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(ee.Error())
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the error of the first failed entry.
+func (e *FlushError) Unwrap() error {
+	if len(e.Entries) == 0 {
+		return nil
+	}
+
+	return e.Entries[0].Err
+}
+
+// KeyCollisionError is returned by the journal Encoder configured with
+// CollisionPolicyError when a user field collides with a reserved field.
+type KeyCollisionError struct {
+	// Key is the original key of the field.
+	Key string
+
+	// Field is the normalized key of the field.
+	Field string
+}
+
+// Error implements error.
+func (e *KeyCollisionError) Error() string {
+	return "logfjournald: field " + strconv.Quote(e.Key) + " collides with reserved field " + e.Field
+}
@@ -0,0 +1,98 @@
+package logfjournald
+
+import (
+	"errors"
+	"strconv"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/ssgreg/logf"
+	"github.com/ssgreg/logfjournald/internal/native"
+)
+
+// isJournalUnavailable checks if the given error means there is nobody
+// listening on the journal socket.
+func isJournalUnavailable(err error) bool {
+	return errors.Is(err, syscall.ENOENT) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ENOTDIR)
+}
+
+// appendStreamLine appends the given native protocol entry to the buf as
+// a single line in sd-daemon(3) format: the priority prefix, the message
+// and the rest of fields as KEY=value pairs.
+//
+//	<6>got cpu info LEVEL=info COUNT=4
+//
+// journald understands the prefix if stderr is connected to it.
+func appendStreamLine(buf *logf.Buffer, msg []byte) error {
+	var priority, message []byte
+	err := native.Parse(msg, func(k, v []byte) {
+		switch string(k) {
+		case DefaultFieldKeyPriority:
+			priority = v
+		case DefaultFieldKeyMessage:
+			message = v
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(priority) == 1 && priority[0] >= '0' && priority[0] <= '7' {
+		buf.AppendByte('<')
+		buf.AppendByte(priority[0])
+		buf.AppendByte('>')
+	}
+	appendStreamValue(buf, message, false)
+
+	native.Parse(msg, func(k, v []byte) {
+		switch string(k) {
+		case DefaultFieldKeyPriority, DefaultFieldKeyMessage:
+			return
+		}
+		buf.AppendByte(' ')
+		buf.AppendBytes(k)
+		buf.AppendByte('=')
+		appendStreamValue(buf, v, true)
+	})
+	buf.AppendByte('\n')
+
+	return nil
+}
+
+// appendStreamValue appends the given value to the buf. The value is
+// quoted if it contains control characters or invalid UTF-8 sequences
+// or, in case of strict mode, spaces, quotes or equal signs.
+func appendStreamValue(buf *logf.Buffer, v []byte, strict bool) {
+	if needsStreamQuoting(v, strict) {
+		buf.Data = strconv.AppendQuote(buf.Data, string(v))
+
+		return
+	}
+	buf.AppendBytes(v)
+}
+
+func needsStreamQuoting(v []byte, strict bool) bool {
+	if strict && len(v) == 0 {
+		return true
+	}
+	for i := 0; i < len(v); {
+		c := v[i]
+		if c >= utf8.RuneSelf {
+			r, wd := utf8.DecodeRune(v[i:])
+			if r == utf8.RuneError && wd == 1 {
+				return true
+			}
+			i += wd
+
+			continue
+		}
+		if c < 0x20 || c == 0x7f || (strict && (c == ' ' || c == '"' || c == '=')) {
+			return true
+		}
+		i++
+	}
+
+	return false
+}
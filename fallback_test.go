@@ -0,0 +1,99 @@
+package logfjournald
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendStreamLine(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Entry  logf.Entry
+		Golden string
+	}{
+		{
+			"Simple",
+			logf.Entry{Level: logf.LevelInfo, Text: "got cpu info", Fields: []logf.Field{logf.Int("count", 4)}},
+			"<6>got cpu info LEVEL=info TS=0001-01-01T00:00:00Z COUNT=4\n",
+		},
+		{
+			"Quoting",
+			logf.Entry{Level: logf.LevelError, Text: "multi\nline", Fields: []logf.Field{
+				logf.String("s", "a b"),
+				logf.String("e", ""),
+				logf.String("q", `"`),
+				logf.String("eq", "a=b"),
+			}},
+			`<3>"multi\nline" LEVEL=error TS=0001-01-01T00:00:00Z S="a b" E="" Q="\"" EQ="a=b"` + "\n",
+		},
+	}
+
+	enc := newTestEncoder(EncoderConfig{})
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			msg := logf.NewBuffer()
+			require.NoError(t, enc.Encode(msg, tc.Entry))
+
+			b := logf.NewBuffer()
+			require.NoError(t, appendStreamLine(b, msg.Bytes()))
+			require.Equal(t, tc.Golden, b.String())
+		})
+	}
+
+	t.Run("NoPriority", func(t *testing.T) {
+		b := logf.NewBuffer()
+		require.NoError(t, appendStreamLine(b, []byte("MESSAGE=m\n")))
+		require.Equal(t, "m\n", b.String())
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		b := logf.NewBuffer()
+		require.Error(t, appendStreamLine(b, []byte("MESSAGE=m")))
+		require.Empty(t, b.Len())
+	})
+}
+
+func TestAppenderFallback(t *testing.T) {
+	var out bytes.Buffer
+	app, close := NewAppenderWithConfig(AppenderConfig{
+		SocketPath:     filepath.Join(t.TempDir(), "socket"),
+		Fallback:       true,
+		FallbackWriter: &out,
+		Encoder:        newTestEncoder(EncoderConfig{}),
+	})
+	defer close()
+
+	require.NoError(t, app.Append(logf.Entry{Level: logf.LevelWarn, Text: "1"}))
+	require.NoError(t, app.Append(logf.Entry{Level: logf.LevelDebug, Text: "2"}))
+	require.NoError(t, app.Flush())
+	require.Equal(t, "<4>1 LEVEL=warn TS=0001-01-01T00:00:00Z\n<7>2 LEVEL=debug TS=0001-01-01T00:00:00Z\n", out.String())
+
+	t.Run("Disabled", func(t *testing.T) {
+		app, close := NewAppenderWithConfig(AppenderConfig{
+			SocketPath:     filepath.Join(t.TempDir(), "socket"),
+			FallbackWriter: &out,
+		})
+		defer close()
+		out.Reset()
+
+		require.NoError(t, app.Append(logf.Entry{Text: "1"}))
+		require.True(t, errors.Is(app.Flush(), syscall.ENOENT))
+		require.Empty(t, out.String())
+	})
+
+	t.Run("OtherErrors", func(t *testing.T) {
+		errTest := errors.New("test")
+		app := newTestAppender(AppenderConfig{Fallback: true, FallbackWriter: &out}, &testJournal{err: errTest})
+		out.Reset()
+
+		require.NoError(t, app.Append(logf.Entry{Text: "1"}))
+		require.True(t, errors.Is(app.Flush(), errTest))
+		require.Empty(t, out.String())
+	})
+}
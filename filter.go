@@ -0,0 +1,52 @@
+package logfjournald
+
+import (
+	"github.com/ssgreg/logf"
+)
+
+// FieldLevelRule restricts user fields to entries of the given severity
+// level or more verbose ones.
+//
+// Example (write REQUEST_BODY for debug entries only):
+//
+//	FieldLevelRule{Keys: []string{"REQUEST_BODY"}, Level: logf.LevelDebug}
+type FieldLevelRule struct {
+	// Keys holds glob patterns in path.Match syntax matched against
+	// original and normalized keys. Normalized keys are matched with and
+	// without FieldKeyPrefix.
+	Keys []string
+
+	// Level is the least verbose level of entries the fields are
+	// written for.
+	Level logf.Level
+}
+
+// keyFilter holds results of matching a key against filtering rules.
+type keyFilter struct {
+	include bool
+	exclude bool
+
+	// rule is the index of the first matching FieldLevelRule or -1.
+	rule int
+}
+
+// matchKeyFilter matches the given keys of a field against filtering
+// rules. A rule matches the field if it matches any of the keys.
+func matchKeyFilter(keys []string, c *EncoderConfig) keyFilter {
+	kf := keyFilter{rule: -1}
+	for _, k := range keys {
+		kf.include = kf.include || matchKeyPattern(k, c.IncludeFields, nil)
+		kf.exclude = kf.exclude || matchKeyPattern(k, c.ExcludeFields, nil)
+	}
+	for i, r := range c.FieldLevelRules {
+		for _, k := range keys {
+			if matchKeyPattern(k, r.Keys, nil) {
+				kf.rule = i
+
+				return kf
+			}
+		}
+	}
+
+	return kf
+}
@@ -0,0 +1,197 @@
+package logfjournald
+
+import (
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoderFilter(t *testing.T) {
+	e := logf.Entry{
+		LoggerID:      1,
+		Level:         logf.LevelInfo,
+		Text:          "m",
+		DerivedFields: []logf.Field{logf.String("request_body", "derived"), logf.String("service", "api")},
+		Fields: []logf.Field{
+			logf.String("user.id", "42"),
+			logf.String("userName", "x"),
+			logf.Object("http", testObject{logf.String("method", "GET"), logf.String("header", "h")}),
+			logf.Strings("tags", []string{"a", "b"}),
+			logf.String("debug_info", "d"),
+		},
+	}
+
+	t.Run("Include", func(t *testing.T) {
+		c := EncoderConfig{
+			FlattenObjects:    true,
+			RepeatSliceFields: true,
+			IncludeFields:     []string{"user.id", "USERNAME", "HTTP_M*", "TAGS", "service"},
+		}
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"SERVICE", []byte("api")},
+			{"USER_ID", []byte("42")},
+			{"USERNAME", []byte("x")},
+			{"HTTP_METHOD", []byte("GET")},
+			{"TAGS", []byte("a")},
+			{"TAGS", []byte("b")},
+		}, encodeTestFields(t, newTestEncoder(c), e))
+	})
+
+	t.Run("Exclude", func(t *testing.T) {
+		c := EncoderConfig{
+			FlattenObjects:    true,
+			RepeatSliceFields: true,
+			IncludeFields:     []string{"*"},
+			ExcludeFields:     []string{"REQUEST_BODY", "HTTP_*", "tags", "debug_*"},
+		}
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+			{"SERVICE", []byte("api")},
+			{"USER_ID", []byte("42")},
+			{"USERNAME", []byte("x")},
+		}, encodeTestFields(t, newTestEncoder(c), e))
+	})
+
+	t.Run("NativeFields", func(t *testing.T) {
+		c := EncoderConfig{ExcludeFields: []string{"*"}}
+		require.Equal(t, []Field{
+			{"PRIORITY", []byte("6")},
+			{"LEVEL", []byte("info")},
+			{"MESSAGE", []byte("m")},
+			{"TS", []byte("0001-01-01T00:00:00Z")},
+		}, encodeTestFields(t, newTestEncoder(c), e))
+	})
+
+	t.Run("LevelRules", func(t *testing.T) {
+		c := EncoderConfig{
+			FieldLevelRules: []FieldLevelRule{
+				{Keys: []string{"REQUEST_BODY"}, Level: logf.LevelDebug},
+				{Keys: []string{"debug_*"}, Level: logf.LevelDebug},
+				{Keys: []string{"DEBUG_INFO"}, Level: logf.LevelError},
+			},
+		}
+		e := logf.Entry{
+			LoggerID:      1,
+			Text:          "m",
+			DerivedFields: e.DerivedFields,
+			Fields:        []logf.Field{logf.String("debug_info", "d")},
+		}
+
+		enc := newTestEncoder(c)
+		// Derived fields of the same logger are cached for each level.
+		for _, lvl := range []logf.Level{logf.LevelInfo, logf.LevelDebug, logf.LevelInfo, logf.LevelWarn} {
+			e.Level = lvl
+			fields := encodeTestEntry(t, enc, e)
+
+			require.Equal(t, []string{"api"}, fields["SERVICE"], lvl.String())
+			if lvl == logf.LevelDebug {
+				require.Equal(t, []string{"derived"}, fields["REQUEST_BODY"], lvl.String())
+				require.Equal(t, []string{"d"}, fields["DEBUG_INFO"], lvl.String())
+			} else {
+				require.NotContains(t, fields, "REQUEST_BODY", lvl.String())
+				require.NotContains(t, fields, "DEBUG_INFO", lvl.String())
+			}
+		}
+	})
+
+	t.Run("FieldKeyPrefix", func(t *testing.T) {
+		c := EncoderConfig{
+			FieldKeyPrefix: "app",
+			FlattenObjects: true,
+			IncludeFields:  []string{"REQUEST_BODY", "SERVICE", "USER_ID", "HTTP_*", "DEBUG_INFO"},
+			ExcludeFields:  []string{"HTTP_HEADER"},
+			FieldLevelRules: []FieldLevelRule{
+				{Keys: []string{"REQUEST_BODY"}, Level: logf.LevelDebug},
+				{Keys: []string{"DEBUG_*"}, Level: logf.LevelDebug},
+			},
+		}
+
+		// Normalized keys are matched without the prefix. Derived fields
+		// of the same logger are cached for each level.
+		enc := newTestEncoder(c)
+		for _, lvl := range []logf.Level{logf.LevelInfo, logf.LevelDebug, logf.LevelInfo} {
+			e := e
+			e.Level = lvl
+			priority := "6"
+			if lvl == logf.LevelDebug {
+				priority = "7"
+			}
+			expected := []Field{
+				{"PRIORITY", []byte(priority)},
+				{"LEVEL", []byte(lvl.String())},
+				{"MESSAGE", []byte("m")},
+				{"TS", []byte("0001-01-01T00:00:00Z")},
+			}
+			if lvl == logf.LevelDebug {
+				expected = append(expected, Field{"APP_REQUEST_BODY", []byte("derived")})
+			}
+			expected = append(expected,
+				Field{"APP_SERVICE", []byte("api")},
+				Field{"APP_USER_ID", []byte("42")},
+				Field{"APP_HTTP_METHOD", []byte("GET")},
+			)
+			if lvl == logf.LevelDebug {
+				expected = append(expected, Field{"APP_DEBUG_INFO", []byte("d")})
+			}
+			require.Equal(t, expected, encodeTestFields(t, enc, e), lvl.String())
+		}
+	})
+
+	t.Run("FlattenedObject", func(t *testing.T) {
+		e := logf.Entry{Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{
+			logf.Object("debug", testObject{logf.String("a", "1"), logf.Object("b", testObject{logf.String("c", "2")})}),
+			logf.Object("http", testObject{logf.String("method", "GET"), logf.Object("url", testObject{logf.String("path", "/")})}),
+			logf.String("after", "v"),
+		}}
+
+		for _, flatten := range []bool{false, true} {
+			// Objects are dropped the same way with and without flattening.
+			for _, c := range []EncoderConfig{
+				{ExcludeFields: []string{"debug"}},
+				{IncludeFields: []string{"http", "after"}},
+				{FieldLevelRules: []FieldLevelRule{{Keys: []string{"DEBUG"}, Level: logf.LevelDebug}}},
+			} {
+				c.FlattenObjects = flatten
+				fields := encodeTestEntry(t, newTestEncoder(c), e)
+				for k := range fields {
+					require.NotContains(t, k, "DEBUG", "%+v", c)
+				}
+				require.Equal(t, []string{"v"}, fields["AFTER"], "%+v", c)
+				if flatten {
+					require.Equal(t, []string{"GET"}, fields["HTTP_METHOD"], "%+v", c)
+					require.Equal(t, []string{"/"}, fields["HTTP_URL_PATH"], "%+v", c)
+				} else {
+					require.Contains(t, fields, "HTTP", "%+v", c)
+				}
+			}
+		}
+	})
+
+	t.Run("BeforeCollision", func(t *testing.T) {
+		c := EncoderConfig{
+			CollisionPolicy: CollisionPolicyError,
+			ExcludeFields:   []string{"message"},
+		}
+		fields := encodeTestEntry(t, newTestEncoder(c), logf.Entry{
+			Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{logf.String("message", "x")},
+		})
+		require.Equal(t, []string{"m"}, fields["MESSAGE"])
+	})
+
+	t.Run("NoAllocs", func(t *testing.T) {
+		c := EncoderConfig{
+			ExcludeFields:   []string{"userName"},
+			FieldLevelRules: []FieldLevelRule{{Keys: []string{"USER_ID"}, Level: logf.LevelDebug}},
+		}
+		e := logf.Entry{LoggerID: 1, Level: logf.LevelInfo, Text: "m", DerivedFields: e.DerivedFields, Fields: e.Fields[:2]}
+		requireNoAllocs(t, newTestEncoder(c), e)
+	})
+}
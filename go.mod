@@ -6,5 +6,5 @@ require (
 	github.com/ssgreg/journald v1.0.0
 	github.com/ssgreg/logf v1.3.1
 	github.com/stretchr/testify v1.7.0
-	golang.org/x/sys v0.0.0-20211111213525-f221eed1c01e // indirect
+	golang.org/x/sys v0.0.0-20211111213525-f221eed1c01e
 )
@@ -0,0 +1,68 @@
+// Package native implements parsing of the systemd journal native
+// protocol entries.
+//
+// Each field of an entry is either serialized as "KEY=value\n" or, if the
+// value may contain newlines or binary data, as "KEY\n" followed by the
+// value size (64-bit little endian), the value and a final newline.
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
+// SyntaxError describes malformed native protocol data.
+type SyntaxError struct {
+	// Offset is the byte offset in the input where the error occurred.
+	Offset int
+	Msg    string
+}
+
+// Error implements error.
+func (e *SyntaxError) Error() string {
+	return "malformed journal entry at offset " + strconv.Itoa(e.Offset) + ": " + e.Msg
+}
+
+// Parse calls fn for each field of the given entry in order of
+// appearance. Both key and value point to the given data.
+func Parse(data []byte, fn func(key, value []byte)) error {
+	for off := 0; off < len(data); {
+		b := data[off:]
+		nl := bytes.IndexByte(b, '\n')
+		switch {
+		case nl == -1:
+			return &SyntaxError{off, "missing newline after field"}
+		case nl == 0:
+			return &SyntaxError{off, "unexpected entry separator"}
+		}
+
+		if eq := bytes.IndexByte(b[:nl], '='); eq != -1 {
+			if eq == 0 {
+				return &SyntaxError{off, "empty field name"}
+			}
+			fn(b[:eq], b[eq+1:nl])
+			off += nl + 1
+
+			continue
+		}
+
+		key := b[:nl]
+		b = b[nl+1:]
+		if len(b) < 8 {
+			return &SyntaxError{off + nl + 1, "truncated value size"}
+		}
+		size := binary.LittleEndian.Uint64(b)
+		b = b[8:]
+		if size >= uint64(len(b)) {
+			return &SyntaxError{off + nl + 9, "value size " + strconv.FormatUint(size, 10) + " exceeds data"}
+		}
+		if b[size] != '\n' {
+			return &SyntaxError{off + nl + 9 + int(size), "missing newline after value"}
+		}
+		fn(key, b[:size])
+		off += nl + 9 + int(size) + 1
+	}
+
+	return nil
+}
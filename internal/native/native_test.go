@@ -0,0 +1,59 @@
+package native
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type field struct {
+	Key   string
+	Value string
+}
+
+func parse(data []byte) ([]field, error) {
+	var fields []field
+	err := Parse(data, func(k, v []byte) {
+		fields = append(fields, field{string(k), string(v)})
+	})
+
+	return fields, err
+}
+
+func TestParse(t *testing.T) {
+	data := []byte("A=1\nB\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\nA=\nC=x=y\n")
+
+	fields, err := parse(data)
+	require.NoError(t, err)
+	require.Equal(t, []field{{"A", "1"}, {"B", "a\nb"}, {"A", ""}, {"C", "x=y"}}, fields)
+
+	fields, err = parse(nil)
+	require.NoError(t, err)
+	require.Empty(t, fields)
+}
+
+func TestParseMalformed(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Data   string
+		Offset int
+	}{
+		{"MissingNewline", "A=1", 0},
+		{"Separator", "A=1\n\nB=2\n", 4},
+		{"EmptyName", "A=1\n=2\n", 4},
+		{"TruncatedSize", "A\n\x01\x00", 2},
+		{"SizeExceedsData", "A\n\x05\x00\x00\x00\x00\x00\x00\x00ab\n", 10},
+		{"MissingNewlineAfterValue", "A\n\x01\x00\x00\x00\x00\x00\x00\x00ab\n", 11},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := parse([]byte(tc.Data))
+
+			var syntaxErr *SyntaxError
+			require.True(t, errors.As(err, &syntaxErr), "%v", err)
+			require.Equal(t, tc.Offset, syntaxErr.Offset)
+		})
+	}
+}
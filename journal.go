@@ -0,0 +1,203 @@
+package logfjournald
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// journal is the interface the appender uses to write encoded entries.
+type journal interface {
+	WriteMsg([]byte) error
+	Close() error
+}
+
+// batchJournal is the journal that is able to write several messages at
+// once.
+type batchJournal interface {
+	journal
+
+	// WriteMsgs writes each of the given messages as a separate datagram.
+	// It returns errors for messages that were not written.
+	WriteMsgs([][]byte) []EntryError
+}
+
+// writeMsgs writes each of the given messages with a separate WriteMsg
+// call. It returns errors for messages that were not written.
+func writeMsgs(j journal, msgs [][]byte) []EntryError {
+	var errs []EntryError
+	for i, msg := range msgs {
+		if err := j.WriteMsg(msg); err != nil {
+			errs = append(errs, EntryError{Index: i, Err: err})
+		}
+	}
+
+	return errs
+}
+
+// brokenJournal is the journal that fails to write anything with the
+// given error.
+type brokenJournal struct {
+	err error
+}
+
+func (j *brokenJournal) WriteMsg([]byte) error {
+	return j.err
+}
+
+func (j *brokenJournal) Close() error {
+	return nil
+}
+
+// socketJournal keeps a connection-less socket to the journal. It is
+// similar to journald.Journal but allows to write several messages with
+// a single sendmmsg(2) call.
+type socketJournal struct {
+	addr *net.UnixAddr
+
+	// fdThreshold is the message size that makes socketJournal to pass
+	// the message via memfd. Zero means that memfd is used only if the
+	// message does not fit in a datagram.
+	fdThreshold int
+
+	once    sync.Once
+	conn    *net.UnixConn
+	connErr error
+
+	// noSendmmsg is set as soon as sendmmsg(2) is found to be
+	// unavailable.
+	noSendmmsg bool
+	mmsg       mmsgScratch
+}
+
+// WriteMsg writes the given bytes to the journal's socket. The caller is
+// in charge of correct data format.
+func (j *socketJournal) WriteMsg(b []byte) error {
+	c, err := j.journalConn()
+	if err != nil {
+		return err
+	}
+	if j.isLarge(b) {
+		return writeFd(c, j.addr, b)
+	}
+	_, _, err = c.WriteMsgUnix(b, nil, j.addr)
+	if isMsgTooLarge(err) {
+		return writeFd(c, j.addr, b)
+	}
+
+	return err
+}
+
+// WriteMsgs writes each of the given messages as a separate datagram
+// using as few sendmmsg(2) calls as possible. It falls back to WriteMsg
+// for each message if sendmmsg(2) is not available.
+func (j *socketJournal) WriteMsgs(msgs [][]byte) []EntryError {
+	if j.noSendmmsg {
+		return writeMsgs(j, msgs)
+	}
+	c, err := j.journalConn()
+	if err != nil {
+		errs := make([]EntryError, len(msgs))
+		for i := range msgs {
+			errs[i] = EntryError{Index: i, Err: err}
+		}
+
+		return errs
+	}
+
+	var errs []EntryError
+	for i := 0; i < len(msgs); {
+		if j.isLarge(msgs[i]) {
+			if err := writeFd(c, j.addr, msgs[i]); err != nil {
+				errs = append(errs, EntryError{Index: i, Err: err})
+			}
+			i++
+
+			continue
+		}
+		end := i + 1
+		for end < len(msgs) && !j.isLarge(msgs[end]) {
+			end++
+		}
+
+		n, err := sendmmsg(c, j.addr, msgs[i:end], &j.mmsg)
+		if errors.Is(err, syscall.ENOSYS) {
+			j.noSendmmsg = true
+			for _, e := range writeMsgs(j, msgs[i:]) {
+				e.Index += i
+				errs = append(errs, e)
+			}
+
+			break
+		}
+		i += n
+		if err != nil {
+			// sendmmsg(2) reports an error only if the first message
+			// from the given ones was not sent. Skip it.
+			if isMsgTooLarge(err) {
+				err = writeFd(c, j.addr, msgs[i])
+			}
+			if err != nil {
+				errs = append(errs, EntryError{Index: i, Err: err})
+			}
+			i++
+		}
+	}
+
+	return errs
+}
+
+func (j *socketJournal) isLarge(b []byte) bool {
+	return j.fdThreshold > 0 && len(b) > j.fdThreshold
+}
+
+// Close closes the underlying connection.
+func (j *socketJournal) Close() error {
+	if j.connErr != nil {
+		return j.connErr
+	}
+	if j.conn == nil {
+		return nil
+	}
+
+	return j.conn.Close()
+}
+
+func (j *socketJournal) journalConn() (*net.UnixConn, error) {
+	j.once.Do(func() {
+		// File from UNIX socket. This is the only way to create
+		// a connection-less socket. Both Dial and ListenUnixgram do
+		// extra work in terms of connection.
+		fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+		if err != nil {
+			j.connErr = os.NewSyscallError("socket", err)
+			return
+		}
+		f := os.NewFile(uintptr(fd), "base UNIX socket")
+		defer f.Close()
+
+		fc, err := net.FileConn(f)
+		if err != nil {
+			j.connErr = err
+			return
+		}
+		uc, ok := fc.(*net.UnixConn)
+		if !ok {
+			fc.Close()
+			j.connErr = errors.New("not a UNIX connection")
+			return
+		}
+		uc.SetWriteBuffer(8 * 1024 * 1024)
+		j.conn = uc
+	})
+
+	return j.conn, j.connErr
+}
+
+// isMsgTooLarge checks if the given error means that the message does not
+// fit in a datagram.
+func isMsgTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
@@ -0,0 +1,78 @@
+package logfjournald
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// listenTestJournal creates a unix datagram socket that stands in for the
+// journal's socket.
+func listenTestJournal(t *testing.T) *net.UnixConn {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "socket"), Net: "unixgram"}
+	c, err := net.ListenUnixgram("unixgram", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		c.Close()
+	})
+
+	return c
+}
+
+// readTestMsgs reads exactly n datagrams from the given socket.
+func readTestMsgs(t *testing.T, c *net.UnixConn, n int) [][]byte {
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(time.Second)))
+
+	var msgs [][]byte
+	b := make([]byte, 64*1024)
+	for i := 0; i < n; i++ {
+		m, err := c.Read(b)
+		require.NoError(t, err)
+		msgs = append(msgs, append([]byte(nil), b[:m]...))
+	}
+
+	return msgs
+}
+
+func TestSocketJournalWriteMsgs(t *testing.T) {
+	c := listenTestJournal(t)
+	j := &socketJournal{addr: c.LocalAddr().(*net.UnixAddr)}
+	defer j.Close()
+
+	msgs := [][]byte{[]byte("MESSAGE=1\n"), []byte("MESSAGE=2\n"), []byte("MESSAGE=3\n")}
+
+	t.Run("Batch", func(t *testing.T) {
+		require.Empty(t, j.WriteMsgs(msgs))
+		require.Equal(t, msgs, readTestMsgs(t, c, len(msgs)))
+	})
+
+	t.Run("BatchReusesScratch", func(t *testing.T) {
+		require.Empty(t, j.WriteMsgs(msgs[:2]))
+		require.Equal(t, msgs[:2], readTestMsgs(t, c, 2))
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		j := &socketJournal{addr: j.addr, noSendmmsg: true}
+		defer j.Close()
+
+		require.Empty(t, j.WriteMsgs(msgs))
+		require.Equal(t, msgs, readTestMsgs(t, c, len(msgs)))
+	})
+}
+
+func TestSocketJournalNotExist(t *testing.T) {
+	j := &socketJournal{addr: &net.UnixAddr{Name: filepath.Join(t.TempDir(), "socket"), Net: "unixgram"}}
+	defer j.Close()
+
+	errs := j.WriteMsgs([][]byte{[]byte("MESSAGE=1\n"), []byte("MESSAGE=2\n")})
+	require.Len(t, errs, 2)
+	for i, e := range errs {
+		require.Equal(t, i, e.Index)
+		require.True(t, errors.Is(e.Err, syscall.ENOENT), "%v", e.Err)
+	}
+}
@@ -0,0 +1,332 @@
+// Package logfjournaldtest provides an in-process fake of the journal's
+// native protocol socket for tests of code that uses logfjournald
+// Appender.
+//
+//	srv := logfjournaldtest.NewServer(t)
+//	app, close := logfjournald.NewAppenderWithConfig(logfjournald.AppenderConfig{
+//		SocketPath: srv.SocketPath(),
+//	})
+//	defer close()
+//	...
+//	srv.ExpectEntry(t, map[string]string{"MESSAGE": "hello", "PRIORITY": "6"})
+package logfjournaldtest
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ssgreg/logfjournald/internal/native"
+)
+
+// DefaultTimeout is the default time to wait for expected entries.
+const DefaultTimeout = time.Second
+
+// maxDatagramSize is the size of the read buffer. Bigger datagrams are
+// reported as errors.
+const maxDatagramSize = 4 * 1024 * 1024
+
+// Entry holds fields of a received journal entry. A field may have more
+// than one value.
+type Entry map[string][]string
+
+// Value returns the first value of the field with the given key.
+func (e Entry) Value(key string) string {
+	if vs := e[key]; len(vs) != 0 {
+		return vs[0]
+	}
+
+	return ""
+}
+
+// Match checks that the Entry contains all the given fields. Each given
+// value must be equal to any of values of the field.
+func (e Entry) Match(fields map[string]string) bool {
+	for k, v := range fields {
+		found := false
+		for _, ev := range e[k] {
+			if ev == v {
+				found = true
+
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the Entry as sorted KEY=value lines.
+func (e Entry) String() string {
+	var lines []string
+	for k, vs := range e {
+		for _, v := range vs {
+			lines = append(lines, k+"="+v)
+		}
+	}
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+// Server is a fake journal. It listens on a unix datagram socket,
+// accepts native protocol entries passed either as datagram payload or
+// as a file descriptor and decodes them.
+type Server struct {
+	// Timeout is the time to wait for expected entries.
+	Timeout time.Duration
+
+	conn *net.UnixConn
+	path string
+	done chan struct{}
+
+	mu      sync.Mutex
+	entries []Entry
+	errs    []error
+	changed chan struct{}
+}
+
+// NewServer starts the new Server listening on a socket in a temporary
+// directory. The Server is closed when the test and all its subtests
+// complete.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s, err := Listen(filepath.Join(t.TempDir(), "socket"))
+	if err != nil {
+		t.Fatalf("logfjournaldtest: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	return s
+}
+
+// Listen starts the new Server listening on the given socket path.
+func Listen(path string) (*Server, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadBuffer(maxDatagramSize)
+
+	s := &Server{
+		Timeout: DefaultTimeout,
+		conn:    conn,
+		path:    path,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+	go s.serve()
+
+	return s, nil
+}
+
+// SocketPath returns the path of the Server's socket.
+func (s *Server) SocketPath() string {
+	return s.path
+}
+
+// Close stops the Server and removes its socket.
+func (s *Server) Close() error {
+	err := s.conn.Close()
+	<-s.done
+	os.Remove(s.path)
+
+	return err
+}
+
+// Entries returns all entries received so far.
+func (s *Server) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Entry(nil), s.entries...)
+}
+
+// Errors returns errors occurred while receiving and decoding entries.
+func (s *Server) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]error(nil), s.errs...)
+}
+
+// Reset forgets all received entries and errors.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = nil
+	s.errs = nil
+}
+
+// WaitEntries waits until the Server receives at least n entries and
+// returns all received entries. It fails the test on timeout.
+func (s *Server) WaitEntries(t testing.TB, n int) []Entry {
+	t.Helper()
+
+	var entries []Entry
+	ok := s.wait(func() bool {
+		entries = s.entries
+		return len(entries) >= n
+	})
+	if !ok {
+		t.Fatalf("logfjournaldtest: got %d entries, want %d; errors: %v", len(entries), n, s.Errors())
+	}
+
+	return append([]Entry(nil), entries...)
+}
+
+// ExpectEntry waits until the Server receives an entry that contains all
+// the given fields and returns the entry. It fails the test on timeout.
+func (s *Server) ExpectEntry(t testing.TB, fields map[string]string) Entry {
+	t.Helper()
+
+	var found Entry
+	ok := s.wait(func() bool {
+		for _, e := range s.entries {
+			if e.Match(fields) {
+				found = e
+				return true
+			}
+		}
+
+		return false
+	})
+	if !ok {
+		var b strings.Builder
+		for _, e := range s.Entries() {
+			b.WriteString("\n---\n")
+			b.WriteString(e.String())
+		}
+		t.Fatalf("logfjournaldtest: no entry matches %v; errors: %v; received entries:%s", fields, s.Errors(), b.String())
+	}
+
+	return found
+}
+
+// wait calls fn under the lock each time the Server receives something
+// until fn returns true or the timeout expires.
+func (s *Server) wait(fn func() bool) bool {
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		ok := fn()
+		changed := s.changed
+		s.mu.Unlock()
+		if ok {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer close(s.done)
+
+	b := make([]byte, maxDatagramSize)
+	oob := make([]byte, syscall.CmsgSpace(4*4))
+	for {
+		n, oobn, flags, _, err := s.conn.ReadMsgUnix(b, oob)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.add(nil, err)
+
+			continue
+		}
+
+		switch {
+		case flags&syscall.MSG_TRUNC != 0:
+			s.add(nil, errors.New("logfjournaldtest: datagram is too large"))
+		case oobn != 0:
+			s.add(readFdMsg(n, oob[:oobn]))
+		default:
+			s.add(decode(b[:n]))
+		}
+	}
+}
+
+func (s *Server) add(e Entry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errs = append(s.errs, err)
+	} else {
+		s.entries = append(s.entries, e)
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// readFdMsg reads the entry from the file passed with SCM_RIGHTS. As the
+// journal does, it requires the datagram to carry exactly one file
+// descriptor and no data.
+func readFdMsg(n int, oob []byte) (Entry, error) {
+	scms, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for i := range scms {
+		rights, err := syscall.ParseUnixRights(&scms[i])
+		if err != nil {
+			return nil, err
+		}
+		fds = append(fds, rights...)
+	}
+	if n != 0 || len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+
+		return nil, errors.New("logfjournaldtest: file descriptor must be passed with no data")
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "journal entry")
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, fi.Size())
+	if _, err = f.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+
+	return decode(b)
+}
+
+func decode(b []byte) (Entry, error) {
+	e := make(Entry)
+	err := native.Parse(b, func(k, v []byte) {
+		e[string(k)] = append(e[string(k)], string(v))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
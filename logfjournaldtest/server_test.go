@@ -0,0 +1,89 @@
+package logfjournaldtest_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ssgreg/logf"
+	"github.com/ssgreg/logfjournald"
+	"github.com/ssgreg/logfjournald/logfjournaldtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	srv := logfjournaldtest.NewServer(t)
+	app, close := logfjournald.NewAppenderWithConfig(logfjournald.AppenderConfig{
+		SocketPath:     srv.SocketPath(),
+		MemfdThreshold: 1000,
+	})
+	defer close()
+
+	require.NoError(t, app.Append(logf.Entry{Level: logf.LevelInfo, Text: "first", Fields: []logf.Field{
+		logf.String("user", "x"),
+	}}))
+	require.NoError(t, app.Append(logf.Entry{Level: logf.LevelError, Text: string(make([]byte, 2000))}))
+	require.NoError(t, app.Flush())
+
+	e := srv.ExpectEntry(t, map[string]string{"MESSAGE": "first", "PRIORITY": "6", "USER": "x"})
+	require.Equal(t, "info", e.Value("LEVEL"))
+	srv.ExpectEntry(t, map[string]string{"MESSAGE": string(make([]byte, 2000)), "PRIORITY": "3"})
+
+	require.Len(t, srv.WaitEntries(t, 2), 2)
+	require.Empty(t, srv.Errors())
+
+	srv.Reset()
+	require.Empty(t, srv.Entries())
+}
+
+func TestServerRepeatedFields(t *testing.T) {
+	srv := logfjournaldtest.NewServer(t)
+	send(t, srv, []byte("TAG=a\nTAG=b\nMESSAGE=m\n"))
+
+	e := srv.ExpectEntry(t, map[string]string{"TAG": "b"})
+	require.Equal(t, []string{"a", "b"}, e["TAG"])
+	require.Equal(t, "a", e.Value("TAG"))
+	require.Equal(t, "MESSAGE=m\nTAG=a\nTAG=b", e.String())
+}
+
+func TestServerMalformed(t *testing.T) {
+	srv := logfjournaldtest.NewServer(t)
+	send(t, srv, []byte("MESSAGE=m"))
+	send(t, srv, []byte("MESSAGE=ok\n"))
+
+	srv.ExpectEntry(t, map[string]string{"MESSAGE": "ok"})
+	require.Len(t, srv.Entries(), 1)
+	require.Len(t, srv.Errors(), 1)
+}
+
+func TestServerTimeout(t *testing.T) {
+	srv := logfjournaldtest.NewServer(t)
+	srv.Timeout = 10 * time.Millisecond
+
+	ft := &fakeT{TB: t}
+	srv.ExpectEntry(ft, map[string]string{"MESSAGE": "m"})
+	require.True(t, ft.failed)
+
+	ft = &fakeT{TB: t}
+	srv.WaitEntries(ft, 1)
+	require.True(t, ft.failed)
+}
+
+func send(t *testing.T, srv *logfjournaldtest.Server, b []byte) {
+	c, err := net.Dial("unixgram", srv.SocketPath())
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write(b)
+	require.NoError(t, err)
+}
+
+// fakeT records Fatalf calls instead of failing the test.
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.failed = true
+}
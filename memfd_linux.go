@@ -0,0 +1,84 @@
+package logfjournald
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// writeFd writes the given data to a sealed memfd and passes it to the
+// journal over SCM_RIGHTS. This is the way the journal accepts entries
+// that do not fit in a single datagram.
+func writeFd(c *net.UnixConn, addr *net.UnixAddr, b []byte) error {
+	f, sealed, err := createDataFile()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+	if sealed {
+		// The journal requires memfd to be sealed, otherwise it could
+		// be changed while being read.
+		_, err = unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL)
+		if err != nil {
+			return os.NewSyscallError("fcntl", err)
+		}
+	}
+
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// The datagram must carry no data. Otherwise the journal
+	// processes the data instead of the file descriptor.
+	oob := unix.UnixRights(int(f.Fd()))
+	to := &unix.SockaddrUnix{Name: addr.Name}
+	var sendErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		sendErr = unix.Sendmsg(int(fd), nil, oob, to, 0)
+		// Wait for the socket to become writable.
+		return sendErr != unix.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return os.NewSyscallError("sendmsg", sendErr)
+	}
+
+	return nil
+}
+
+// createDataFile creates a memfd. It falls back to an unlinked temporary
+// file in /dev/shm if memfd is not available, the same way the reference
+// sd_journal_send implementation does.
+func createDataFile() (f *os.File, sealed bool, err error) {
+	fd, err := unix.MemfdCreate("logfjournald", unix.MFD_ALLOW_SEALING|unix.MFD_CLOEXEC)
+	if err == nil {
+		return os.NewFile(uintptr(fd), "logfjournald memfd"), true, nil
+	}
+	if !errors.Is(err, syscall.ENOSYS) && !errors.Is(err, syscall.EINVAL) {
+		return nil, false, os.NewSyscallError("memfd_create", err)
+	}
+
+	f, err = ioutil.TempFile("/dev/shm", "logfjournald-")
+	if err != nil {
+		return nil, false, err
+	}
+	// The file is removed when the journal closes the passed descriptor.
+	if err = os.Remove(f.Name()); err != nil {
+		f.Close()
+
+		return nil, false, err
+	}
+
+	return f, false, nil
+}
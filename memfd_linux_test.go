@@ -0,0 +1,96 @@
+package logfjournald
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// readTestFdMsg reads a datagram that carries no data but a file
+// descriptor and returns the file's content.
+func readTestFdMsg(t *testing.T, c *net.UnixConn) []byte {
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(time.Second)))
+
+	b := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := c.ReadMsgUnix(b, oob)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	scms, err := unix.ParseSocketControlMessage(oob[:oobn])
+	require.NoError(t, err)
+	require.Len(t, scms, 1)
+	fds, err := unix.ParseUnixRights(&scms[0])
+	require.NoError(t, err)
+	require.Len(t, fds, 1)
+
+	f := os.NewFile(uintptr(fds[0]), "received fd")
+	defer f.Close()
+
+	seals, err := unix.FcntlInt(f.Fd(), unix.F_GET_SEALS, 0)
+	require.NoError(t, err)
+	require.Equal(t, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL, seals)
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	data := make([]byte, fi.Size())
+	_, err = f.ReadAt(data, 0)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestSocketJournalMemfd(t *testing.T) {
+	c := listenTestJournal(t)
+	j := &socketJournal{addr: c.LocalAddr().(*net.UnixAddr), fdThreshold: 10}
+	defer j.Close()
+
+	small := []byte("MESSAGE=1\n")
+	large := []byte("MESSAGE=large\n")
+
+	t.Run("WriteMsg", func(t *testing.T) {
+		require.NoError(t, j.WriteMsg(small))
+		require.NoError(t, j.WriteMsg(large))
+
+		require.Equal(t, [][]byte{small}, readTestMsgs(t, c, 1))
+		require.Equal(t, large, readTestFdMsg(t, c))
+	})
+
+	t.Run("WriteMsgs", func(t *testing.T) {
+		require.Empty(t, j.WriteMsgs([][]byte{large, small, small, large}))
+
+		require.Equal(t, large, readTestFdMsg(t, c))
+		require.Equal(t, [][]byte{small, small}, readTestMsgs(t, c, 2))
+		require.Equal(t, large, readTestFdMsg(t, c))
+	})
+
+	t.Run("Oversized", func(t *testing.T) {
+		j := &socketJournal{addr: j.addr}
+		defer j.Close()
+
+		huge := make([]byte, 64*1024*1024)
+		require.NoError(t, j.WriteMsg(huge))
+		require.Equal(t, huge, readTestFdMsg(t, c))
+	})
+}
+
+func TestAppenderMemfdThreshold(t *testing.T) {
+	c := listenTestJournal(t)
+	app, close := NewAppenderWithConfig(AppenderConfig{
+		SocketPath:     c.LocalAddr().String(),
+		MemfdThreshold: 1000,
+	})
+	defer close()
+
+	require.NoError(t, app.Append(logf.Entry{Level: logf.LevelInfo, Text: "small"}))
+	require.NoError(t, app.Append(logf.Entry{Level: logf.LevelInfo, Text: string(make([]byte, 2000))}))
+	require.NoError(t, app.Flush())
+
+	require.Equal(t, "small", decodeTestEntry(t, readTestMsgs(t, c, 1)[0])["MESSAGE"])
+	require.Equal(t, string(make([]byte, 2000)), decodeTestEntry(t, readTestFdMsg(t, c))["MESSAGE"])
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package logfjournald
+
+import (
+	"net"
+	"syscall"
+)
+
+// writeFd is not supported on this system.
+func writeFd(c *net.UnixConn, addr *net.UnixAddr, b []byte) error {
+	return syscall.ENOTSUP
+}
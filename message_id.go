@@ -0,0 +1,72 @@
+package logfjournald
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/ssgreg/logf"
+)
+
+// MessageID is the 128-bit identifier of a message type. The journal
+// Encoder writes it as the native MESSAGE_ID field that links entries to
+// the message catalog explaining them (see journalctl -x).
+type MessageID [16]byte
+
+// ParseMessageID parses the MessageID from 32 hexadecimal characters as
+// printed by journalctl --new-id128.
+func ParseMessageID(s string) (MessageID, error) {
+	var id MessageID
+	if len(s) != hex.EncodedLen(len(id)) {
+		return id, errors.New("logfjournald: invalid message ID " + strconv.Quote(s) + ": must be 32 hexadecimal characters")
+	}
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil {
+		return id, errors.New("logfjournald: invalid message ID " + strconv.Quote(s) + ": " + err.Error())
+	}
+
+	return id, nil
+}
+
+// MustParseMessageID is like ParseMessageID but panics if the given
+// string is not a valid MessageID. It simplifies initialization of
+// global variables.
+func MustParseMessageID(s string) MessageID {
+	id, err := ParseMessageID(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return id
+}
+
+// String implements fmt.Stringer. It returns 32 lowercase hexadecimal
+// characters.
+func (id MessageID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id MessageID) MarshalText() ([]byte, error) {
+	b := make([]byte, hex.EncodedLen(len(id)))
+	hex.Encode(b, id[:])
+
+	return b, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *MessageID) UnmarshalText(text []byte) error {
+	parsed, err := ParseMessageID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+
+	return nil
+}
+
+// MessageIDField returns a new Field with the given MessageID. The journal
+// Encoder writes it as the native MESSAGE_ID field. Other encoders get
+// a field with the textual representation of the MessageID.
+func MessageIDField(id MessageID) logf.Field {
+	return logf.Field{Key: DefaultFieldKeyMessageID, Type: logf.FieldTypeAny, Any: id}
+}
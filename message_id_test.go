@@ -0,0 +1,55 @@
+package logfjournald
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+const testMessageID = "fc2e22bc6ee647b6b90729ab34a250b1"
+
+func TestParseMessageID(t *testing.T) {
+	id, err := ParseMessageID("FC2E22BC6EE647B6B90729AB34A250B1")
+	require.NoError(t, err)
+	require.Equal(t, testMessageID, id.String())
+	require.Equal(t, MustParseMessageID(testMessageID), id)
+
+	for _, s := range []string{"", "fc2e22bc", testMessageID + "00", "zc2e22bc6ee647b6b90729ab34a250b1", "fc2e22bc-6ee6-47b6-b907-29ab34a250b1"} {
+		_, err := ParseMessageID(s)
+		require.Error(t, err, s)
+	}
+	require.Panics(t, func() { MustParseMessageID("bad") })
+}
+
+func TestMessageIDText(t *testing.T) {
+	id := MustParseMessageID(testMessageID)
+
+	b, err := json.Marshal(id)
+	require.NoError(t, err)
+	require.Equal(t, `"`+testMessageID+`"`, string(b))
+
+	var parsed MessageID
+	require.NoError(t, json.Unmarshal(b, &parsed))
+	require.Equal(t, id, parsed)
+	require.Error(t, json.Unmarshal([]byte(`"bad"`), &parsed))
+}
+
+func TestEncoderMessageID(t *testing.T) {
+	id := MustParseMessageID(testMessageID)
+	enc := newTestEncoder(EncoderConfig{})
+
+	b := logf.NewBuffer()
+	require.NoError(t, enc.Encode(b, logf.Entry{
+		Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{MessageIDField(id)},
+	}))
+	require.Equal(t, testMessageID, decodeTestEntry(t, b.Bytes())["MESSAGE_ID"])
+
+	// Other encoders see the textual representation.
+	b = logf.NewBuffer()
+	require.NoError(t, logf.NewJSONEncoder.Default().Encode(b, logf.Entry{
+		Level: logf.LevelInfo, Text: "m", Fields: []logf.Field{MessageIDField(id)},
+	}))
+	require.Contains(t, b.String(), `"MESSAGE_ID":"`+testMessageID+`"`)
+}
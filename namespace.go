@@ -0,0 +1,67 @@
+package logfjournald
+
+import (
+	"path/filepath"
+	"strconv"
+)
+
+// journalRuntimeDir is the directory holding journal sockets.
+var journalRuntimeDir = "/run/systemd"
+
+// namespaceMaxLen is the maximum length of the journal namespace name.
+// The name must fit in a file name prefixed with the machine ID and
+// a dot.
+const namespaceMaxLen = 255 - 33
+
+// NamespaceError describes an invalid journal namespace name.
+type NamespaceError struct {
+	Namespace string
+	Reason    string
+}
+
+// Error implements error.
+func (e *NamespaceError) Error() string {
+	return "logfjournald: invalid journal namespace " + strconv.Quote(e.Namespace) + ": " + e.Reason
+}
+
+// ValidateNamespace checks that the given name is a valid journal
+// namespace name (see LogNamespace= in systemd.exec(5)). The name
+// "default" is reserved by systemd for the default namespace, use the
+// empty name instead.
+func ValidateNamespace(ns string) error {
+	switch {
+	case ns == "":
+		return &NamespaceError{ns, "empty name"}
+	case ns == "." || ns == "..":
+		return &NamespaceError{ns, "not a valid file name"}
+	case ns == "default":
+		return &NamespaceError{ns, "reserved for the default namespace"}
+	case len(ns) > namespaceMaxLen:
+		return &NamespaceError{ns, "name is longer than " + strconv.Itoa(namespaceMaxLen) + " bytes"}
+	}
+	for i := 0; i < len(ns); i++ {
+		c := ns[i]
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c == '_', c == '-', c == '.', c == ':':
+		default:
+			return &NamespaceError{ns, "invalid character " + strconv.QuoteRune(rune(c))}
+		}
+	}
+
+	return nil
+}
+
+// NamespaceSocketPath returns the path of the native protocol socket of
+// the journal namespace with the given name. Empty name stands for the
+// default namespace.
+func NamespaceSocketPath(ns string) (string, error) {
+	if ns == "" {
+		return DefaultSocketPath, nil
+	}
+	if err := ValidateNamespace(ns); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(journalRuntimeDir, "journal."+ns, "socket"), nil
+}
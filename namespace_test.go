@@ -0,0 +1,101 @@
+package logfjournald
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	for _, ns := range []string{"a", "tenant-1", "Tenant_2", "a.b", "a:b"} {
+		require.NoError(t, ValidateNamespace(ns), ns)
+	}
+
+	for _, ns := range []string{"", ".", "..", "default", "a/b", "a b", "a*", "ns\n", strings.Repeat("a", namespaceMaxLen+1)} {
+		err := ValidateNamespace(ns)
+		var nsErr *NamespaceError
+		require.True(t, errors.As(err, &nsErr), ns)
+		require.Equal(t, ns, nsErr.Namespace)
+	}
+}
+
+func TestNamespaceSocketPath(t *testing.T) {
+	path, err := NamespaceSocketPath("")
+	require.NoError(t, err)
+	require.Equal(t, DefaultSocketPath, path)
+
+	path, err = NamespaceSocketPath("tenant")
+	require.NoError(t, err)
+	require.Equal(t, "/run/systemd/journal.tenant/socket", path)
+
+	_, err = NamespaceSocketPath("../tenant")
+	require.Error(t, err)
+
+	_, err = NamespaceSocketPath("default")
+	require.Error(t, err)
+}
+
+func TestAppenderNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	defer func(old string) {
+		journalRuntimeDir = old
+	}(journalRuntimeDir)
+	journalRuntimeDir = dir
+
+	listen := func(ns string) *net.UnixConn {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "journal."+ns), 0o755))
+		c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(dir, "journal."+ns, "socket"), Net: "unixgram"})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			c.Close()
+		})
+
+		return c
+	}
+	ca, cb := listen("a"), listen("b")
+
+	appA, closeA := NewAppenderWithConfig(AppenderConfig{Namespace: "a"})
+	defer closeA()
+	appB, closeB := NewAppenderWithConfig(AppenderConfig{Namespace: "b"})
+	defer closeB()
+
+	require.NoError(t, appA.Append(logf.Entry{Text: "to a"}))
+	require.NoError(t, appB.Append(logf.Entry{Text: "to b"}))
+	require.NoError(t, appA.Flush())
+	require.NoError(t, appB.Flush())
+
+	require.Equal(t, "to a", decodeTestEntry(t, readTestMsgs(t, ca, 1)[0])["MESSAGE"])
+	require.Equal(t, "to b", decodeTestEntry(t, readTestMsgs(t, cb, 1)[0])["MESSAGE"])
+
+	t.Run("NotExist", func(t *testing.T) {
+		app, close := NewAppenderWithConfig(AppenderConfig{Namespace: "c"})
+		defer close()
+
+		require.NoError(t, app.Append(logf.Entry{Text: "to c"}))
+		err := app.Flush()
+
+		var flushErr *FlushError
+		require.True(t, errors.As(err, &flushErr))
+		require.Equal(t, "c", flushErr.Namespace)
+		require.Equal(t, filepath.Join(dir, "journal.c", "socket"), flushErr.SocketPath)
+		require.Contains(t, err.Error(), `namespace "c"`)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		app, close := NewAppenderWithConfig(AppenderConfig{Namespace: "a/b"})
+		defer close()
+
+		require.NoError(t, app.Append(logf.Entry{Text: "to a/b"}))
+		err := app.Flush()
+
+		var nsErr *NamespaceError
+		require.True(t, errors.As(err, &nsErr))
+		require.Equal(t, "a/b", nsErr.Namespace)
+	})
+}
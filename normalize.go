@@ -6,12 +6,18 @@ import (
 	"github.com/ssgreg/logf"
 )
 
-// appendNormalizedKey appends normalized key to the buf. The journal key
-// name must be in uppercase and consist only of characters, numbers and
-// underscores, and may not begin with an underscore.
-func appendNormalizedKey(buf *logf.Buffer, s string) {
-	for i := 0; i < len(s); {
-		c := s[i]
+// KeyNormalizer appends the journal field name for the given key to the
+// buf. The Encoder replaces characters that are not allowed in journal
+// field names with underscores, prepends LOGF to names beginning with an
+// underscore or a digit and limits the length of names afterwards.
+type KeyNormalizer func(buf *logf.Buffer, k string)
+
+// DefaultKeyNormalizer uppercases the key and replaces each character
+// that is not a letter or a digit with an underscore, e.g. requestId
+// becomes REQUESTID and request.id becomes REQUEST_ID.
+func DefaultKeyNormalizer(buf *logf.Buffer, k string) {
+	for i := 0; i < len(k); {
+		c := k[i]
 		switch {
 		case ((c >= 0x30 && c <= 0x39) || (c >= 0x41 && c <= 0x5a)):
 			buf.AppendByte(c)
@@ -20,12 +26,213 @@ func appendNormalizedKey(buf *logf.Buffer, s string) {
 			buf.AppendByte(c - 0x20)
 			i++
 		default:
-			if i == 0 {
-				buf.AppendString("LOGF")
+			buf.AppendByte('_')
+			_, wd := utf8.DecodeRuneInString(k[i:])
+			i += wd
+		}
+	}
+}
+
+// CamelCaseKeyNormalizer is like DefaultKeyNormalizer but splits camelCase
+// words with underscores, e.g. requestId becomes REQUEST_ID and HTTPServer
+// becomes HTTP_SERVER.
+func CamelCaseKeyNormalizer(buf *logf.Buffer, k string) {
+	for i := 0; i < len(k); {
+		c := k[i]
+		switch {
+		case c >= 0x41 && c <= 0x5a:
+			if i != 0 && (isLowerOrDigit(k[i-1]) || (isUpper(k[i-1]) && i+1 < len(k) && isLower(k[i+1]))) {
+				buf.AppendByte('_')
 			}
+			buf.AppendByte(c)
+			i++
+		case c >= 0x61 && c <= 0x7a:
+			buf.AppendByte(c - 0x20)
+			i++
+		case c >= 0x30 && c <= 0x39:
+			buf.AppendByte(c)
+			i++
+		default:
 			buf.AppendByte('_')
-			_, wd := utf8.DecodeRuneInString(s[i:])
+			_, wd := utf8.DecodeRuneInString(k[i:])
+			i += wd
+		}
+	}
+}
+
+// DottedPathKeyNormalizer is like DefaultKeyNormalizer but converts each
+// run of characters that are not letters or digits to a single
+// underscore and drops the trailing one, e.g. http..request.id. becomes
+// HTTP_REQUEST_ID.
+func DottedPathKeyNormalizer(buf *logf.Buffer, k string) {
+	start := buf.Len()
+	for i := 0; i < len(k); {
+		c := k[i]
+		switch {
+		case ((c >= 0x30 && c <= 0x39) || (c >= 0x41 && c <= 0x5a)):
+			buf.AppendByte(c)
+			i++
+		case c >= 0x61 && c <= 0x7a:
+			buf.AppendByte(c - 0x20)
+			i++
+		default:
+			if buf.Len() == start || buf.Back() != '_' {
+				buf.AppendByte('_')
+			}
+			_, wd := utf8.DecodeRuneInString(k[i:])
 			i += wd
 		}
 	}
+	if buf.Len() > start && buf.Back() == '_' {
+		buf.Data = buf.Data[:buf.Len()-1]
+	}
+}
+
+// NormalizeKey returns the journal field name the Encoder with the
+// DefaultKeyNormalizer uses for a user field with the given key. Neither
+// FieldKeyPrefix nor the collision policy of the Encoder is taken into
+// account.
+func NormalizeKey(k string) string {
+	buf := logf.NewBuffer()
+	appendNormalizedKey(buf, k)
+
+	return buf.String()
+}
+
+// appendNormalizedKey appends the key normalized with DefaultKeyNormalizer
+// to the buf.
+func appendNormalizedKey(buf *logf.Buffer, k string) {
+	appendKey(buf, k, DefaultKeyNormalizer)
+}
+
+// appendNormalizedKeyPart is like appendNormalizedKey but appends a part
+// of the key that is not at the beginning of the key, e.g. a separator of
+// nested keys.
+func appendNormalizedKeyPart(buf *logf.Buffer, k string) {
+	appendKeyPart(buf, k, DefaultKeyNormalizer)
+}
+
+// appendKey appends the key normalized with the given KeyNormalizer to the
+// buf. The journal key name must be in uppercase and consist only of
+// characters, numbers and underscores, may not begin with an underscore or
+// a digit and may not be longer than 64 bytes.
+func appendKey(buf *logf.Buffer, k string, n KeyNormalizer) {
+	start := buf.Len()
+	appendKeyPart(buf, k, n)
+
+	switch {
+	case buf.Len() == start:
+		buf.AppendString("LOGF")
+	case buf.Data[start] == '_':
+		insertKeyPrefix(buf, start, "LOGF")
+	case buf.Data[start] >= 0x30 && buf.Data[start] <= 0x39:
+		insertKeyPrefix(buf, start, "LOGF_")
+	}
+	limitKey(buf, start)
+}
+
+// appendKeyPart is like appendKey but appends a part of the key that is
+// not at the beginning of the key. Only characters are checked.
+func appendKeyPart(buf *logf.Buffer, k string, n KeyNormalizer) {
+	start := buf.Len()
+	n(buf, k)
+
+	b := buf.Data[start:]
+	for i, c := range b {
+		switch {
+		case c >= 0x61 && c <= 0x7a:
+			b[i] = c - 0x20
+		case !isKeyChar(c):
+			b[i] = '_'
+		}
+	}
+}
+
+// insertKeyPrefix inserts the prefix to the buf at the given position.
+func insertKeyPrefix(buf *logf.Buffer, pos int, prefix string) {
+	n := len(prefix)
+	buf.ExtendBytes(n)
+	copy(buf.Data[pos+n:], buf.Data[pos:])
+	copy(buf.Data[pos:], prefix)
+}
+
+// limitKey shortens the key started at the given position of the buf to
+// the maximum length accepted by the journal. The tail of the long key is
+// replaced with the FNV-1a hash of the whole key to keep different long
+// keys different.
+func limitKey(buf *logf.Buffer, start int) {
+	key := buf.Data[start:]
+	if len(key) <= maxFieldKeyLen {
+		return
+	}
+
+	h := uint32(2166136261)
+	for _, c := range key {
+		h ^= uint32(c)
+		h *= 16777619
+	}
+
+	const digits = "0123456789ABCDEF"
+	buf.Data = buf.Data[:start+maxFieldKeyLen-9]
+	buf.AppendByte('_')
+	for shift := 28; shift >= 0; shift -= 4 {
+		buf.AppendByte(digits[(h>>uint(shift))&0xf])
+	}
+}
+
+func isKeyChar(c byte) bool {
+	return (c >= 0x30 && c <= 0x39) || (c >= 0x41 && c <= 0x5a) || c == '_'
+}
+
+func isUpper(c byte) bool {
+	return c >= 0x41 && c <= 0x5a
+}
+
+func isLower(c byte) bool {
+	return c >= 0x61 && c <= 0x7a
+}
+
+func isLowerOrDigit(c byte) bool {
+	return isLower(c) || (c >= 0x30 && c <= 0x39)
+}
+
+// maxKeyCacheSize limits the number of keys cached by keyCache.
+const maxKeyCacheSize = 1024
+
+// keyInfo holds the journal field name of a user field and results of
+// matching its keys against the rules of the Encoder.
+type keyInfo struct {
+	// key is the journal field name including FieldKeyPrefix and keys
+	// of flattened objects. part is the normalized key alone.
+	key  []byte
+	part []byte
+
+	reserved bool
+	filter   keyFilter
+	redact   bool
+}
+
+// keyCache holds keyInfo of user fields to avoid normalizing and matching
+// repeated keys. The number of keys is limited to protect from unbounded
+// growth with dynamic keys.
+type keyCache struct {
+	m map[string]keyInfo
+}
+
+func (c *keyCache) get(k []byte) (keyInfo, bool) {
+	v, ok := c.m[string(k)]
+
+	return v, ok
+}
+
+func (c *keyCache) set(k []byte, v keyInfo) {
+	if len(c.m) >= maxKeyCacheSize {
+		return
+	}
+	if c.m == nil {
+		c.m = make(map[string]keyInfo)
+	}
+	v.key = append([]byte(nil), v.key...)
+	v.part = append([]byte(nil), v.part...)
+	c.m[string(k)] = v
 }
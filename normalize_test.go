@@ -1,6 +1,8 @@
 package logfjournald
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/ssgreg/logf"
@@ -21,6 +23,11 @@ func TestAppendNormalizedKey(t *testing.T) {
 		{"StartWithInvalidChar", "!F", "LOGF_F"},
 		{"Uppercasing", "abcdefghijklmnopqrstuvwxyz", "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
 		{"InvalidChars", "!@#$%^&*()-=\n\r\a<>?ГЗ:;'\\|?.,~[]{}", "LOGF_________________________________"},
+		{"StartWithDigit", "1F", "LOGF_1F"},
+		{"Empty", "", "LOGF"},
+		{"MaxLength", strings.Repeat("a", 64), strings.Repeat("A", 64)},
+		{"TooLong", strings.Repeat("a", 65), strings.Repeat("A", 55) + "_D612AF8C"},
+		{"TooLongWithPrefix", strings.Repeat("1", 70), "LOGF_" + strings.Repeat("1", 50) + "_C14319E0"},
 	}
 
 	for _, tc := range testCases {
@@ -32,3 +39,108 @@ func TestAppendNormalizedKey(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeKey(t *testing.T) {
+	require.Equal(t, "REQUEST_ID", NormalizeKey("request.id"))
+	require.Equal(t, "LOGF_PID", NormalizeKey("_pid"))
+
+	long := strings.Repeat("field_", 20)
+	k1 := NormalizeKey(long + "a")
+	k2 := NormalizeKey(long + "b")
+	require.Len(t, k1, 64)
+	require.Len(t, k2, 64)
+	require.NotEqual(t, k1, k2)
+	require.Equal(t, k1, NormalizeKey(long+"a"))
+}
+
+func TestEncoderKeyLimit(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	enc := newTestEncoder(EncoderConfig{FlattenObjects: true})
+	b := logf.NewBuffer()
+	require.NoError(t, enc.Encode(b, logf.Entry{
+		Level: logf.LevelInfo,
+		Text:  "m",
+		Fields: []logf.Field{
+			logf.String(long+long, "1"),
+			logf.Object(long, testObject{logf.String(long, "2")}),
+		},
+	}))
+
+	// Decode fails on invalid or long keys.
+	fields := decodeTestEntry(t, b.Bytes())
+	require.Equal(t, "1", fields[NormalizeKey(long+long)])
+	require.Equal(t, "2", fields[NormalizeKey(long+"_"+long)])
+}
+
+func TestKeyNormalizers(t *testing.T) {
+	testCases := []struct {
+		Name       string
+		Normalizer KeyNormalizer
+		Testing    string
+		Golden     string
+	}{
+		{"DefaultCamelCase", DefaultKeyNormalizer, "requestId", "REQUESTID"},
+		{"DefaultDotted", DefaultKeyNormalizer, "http..request.id.", "HTTP__REQUEST_ID_"},
+		{"CamelCase", CamelCaseKeyNormalizer, "requestId", "REQUEST_ID"},
+		{"CamelCaseAcronym", CamelCaseKeyNormalizer, "HTTPServer", "HTTP_SERVER"},
+		{"CamelCaseTrailingAcronym", CamelCaseKeyNormalizer, "userID", "USER_ID"},
+		{"CamelCaseDigits", CamelCaseKeyNormalizer, "version2Beta", "VERSION2_BETA"},
+		{"CamelCaseUpper", CamelCaseKeyNormalizer, "CODE_FILE", "CODE_FILE"},
+		{"CamelCaseSnake", CamelCaseKeyNormalizer, "user_Id", "USER_ID"},
+		{"CamelCaseLeadingUnderscore", CamelCaseKeyNormalizer, "_pid", "LOGF_PID"},
+		{"DottedPath", DottedPathKeyNormalizer, "http..request.id.", "HTTP_REQUEST_ID"},
+		{"DottedPathLeading", DottedPathKeyNormalizer, ".-id", "LOGF_ID"},
+		{"DottedPathOnlySeparators", DottedPathKeyNormalizer, "..", "LOGF"},
+		{"Custom", func(buf *logf.Buffer, k string) { buf.AppendString("app." + k) }, "id", "APP_ID"},
+		{"CustomEmpty", func(buf *logf.Buffer, k string) {}, "id", "LOGF"},
+		{"CustomDigit", func(buf *logf.Buffer, k string) { buf.AppendString("1") }, "id", "LOGF_1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := logf.NewBuffer()
+			b.AppendString("PREV")
+			appendKey(b, tc.Testing, tc.Normalizer)
+
+			require.EqualValues(t, "PREV"+tc.Golden, b.String())
+		})
+	}
+}
+
+func TestEncoderNormalizeKey(t *testing.T) {
+	enc := newTestEncoder(EncoderConfig{NormalizeKey: CamelCaseKeyNormalizer, FlattenObjects: true})
+	e := logf.Entry{
+		Level: logf.LevelInfo,
+		Text:  "m",
+		Fields: []logf.Field{
+			logf.String("requestId", "1"),
+			logf.Object("httpRequest", testObject{logf.String("remoteAddr", "2")}),
+		},
+	}
+
+	fields := encodeTestEntry(t, enc, e)
+	require.Equal(t, []string{"1"}, fields["REQUEST_ID"])
+	require.Equal(t, []string{"2"}, fields["HTTP_REQUEST_REMOTE_ADDR"])
+	require.Equal(t, []string{"m"}, fields["MESSAGE"])
+
+	requireNoAllocs(t, enc, e)
+}
+
+func TestKeyCache(t *testing.T) {
+	var c keyCache
+	_, ok := c.get([]byte("a"))
+	require.False(t, ok)
+
+	k := []byte("a")
+	v := keyInfo{key: []byte("A"), part: []byte("A"), redact: true}
+	c.set(k, v)
+	k[0], v.key[0], v.part[0] = 'b', 'B', 'B'
+	cached, ok := c.get([]byte("a"))
+	require.True(t, ok)
+	require.Equal(t, keyInfo{key: []byte("A"), part: []byte("A"), redact: true}, cached)
+
+	for i := 0; i < maxKeyCacheSize*2; i++ {
+		c.set([]byte(strconv.Itoa(i)), v)
+	}
+	require.Len(t, c.m, maxKeyCacheSize)
+}
@@ -0,0 +1,54 @@
+package logfjournald
+
+import (
+	"github.com/ssgreg/journald"
+	"github.com/ssgreg/logf"
+)
+
+// LevelPriorityEncoder is the function type to map the given Level to the
+// journal's PRIORITY.
+type LevelPriorityEncoder func(logf.Level) journald.Priority
+
+// DefaultLevelPriorityEncoder maps logf severity levels to the matching
+// syslog priorities. Unknown levels are mapped to PriorityNotice.
+func DefaultLevelPriorityEncoder(lvl logf.Level) journald.Priority {
+	switch lvl {
+	case logf.LevelDebug:
+		return journald.PriorityDebug
+	case logf.LevelInfo:
+		return journald.PriorityInfo
+	case logf.LevelWarn:
+		return journald.PriorityWarning
+	case logf.LevelError:
+		return journald.PriorityErr
+	default:
+		return journald.PriorityNotice
+	}
+}
+
+// NewLevelPriorityEncoder creates the new instance of LevelPriorityEncoder
+// that maps levels using the given table. Levels missing in the table
+// are mapped with DefaultLevelPriorityEncoder.
+//
+// Example:
+//
+// 	NewLevelPriorityEncoder(map[logf.Level]journald.Priority{
+// 		LevelAudit: journald.PriorityNotice,
+// 		LevelFatal: journald.PriorityCrit,
+// 	})
+//
+func NewLevelPriorityEncoder(m map[logf.Level]journald.Priority) LevelPriorityEncoder {
+	// Copy the table to protect it from further changes.
+	table := make(map[logf.Level]journald.Priority, len(m))
+	for lvl, p := range m {
+		table[lvl] = p
+	}
+
+	return func(lvl logf.Level) journald.Priority {
+		if p, ok := table[lvl]; ok {
+			return p
+		}
+
+		return DefaultLevelPriorityEncoder(lvl)
+	}
+}
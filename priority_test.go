@@ -0,0 +1,47 @@
+package logfjournald
+
+import (
+	"testing"
+
+	"github.com/ssgreg/journald"
+	"github.com/ssgreg/logf"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testLevelAudit logf.Level = logf.LevelDebug + 1 + iota
+	testLevelFatal
+)
+
+func TestDefaultLevelPriorityEncoder(t *testing.T) {
+	require.Equal(t, journald.PriorityDebug, DefaultLevelPriorityEncoder(logf.LevelDebug))
+	require.Equal(t, journald.PriorityInfo, DefaultLevelPriorityEncoder(logf.LevelInfo))
+	require.Equal(t, journald.PriorityWarning, DefaultLevelPriorityEncoder(logf.LevelWarn))
+	require.Equal(t, journald.PriorityErr, DefaultLevelPriorityEncoder(logf.LevelError))
+	require.Equal(t, journald.PriorityNotice, DefaultLevelPriorityEncoder(testLevelAudit))
+}
+
+func TestNewLevelPriorityEncoder(t *testing.T) {
+	m := map[logf.Level]journald.Priority{
+		testLevelFatal: journald.PriorityCrit,
+		logf.LevelInfo: journald.PriorityNotice,
+	}
+	enc := NewLevelPriorityEncoder(m)
+	delete(m, testLevelFatal)
+
+	require.Equal(t, journald.PriorityCrit, enc(testLevelFatal))
+	require.Equal(t, journald.PriorityNotice, enc(logf.LevelInfo))
+	require.Equal(t, journald.PriorityNotice, enc(testLevelAudit))
+	require.Equal(t, journald.PriorityErr, enc(logf.LevelError))
+}
+
+func TestEncoderLevelPriority(t *testing.T) {
+	enc := NewEncoder(EncoderConfig{
+		EncodeLevelPriority: NewLevelPriorityEncoder(map[logf.Level]journald.Priority{
+			testLevelFatal: journald.PriorityCrit,
+		}),
+	}, logf.NewJSONTypeEncoderFactory.Default())
+
+	fields := encodeTestEntry(t, enc, logf.Entry{Level: testLevelFatal, Text: "m"})
+	require.Equal(t, []string{"2"}, fields["PRIORITY"])
+}
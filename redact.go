@@ -0,0 +1,117 @@
+package logfjournald
+
+import (
+	"path"
+	"regexp"
+)
+
+// RedactMode defines how the journal Encoder replaces values of redacted
+// fields.
+type RedactMode int
+
+// Available redaction modes.
+const (
+	// RedactModeMask replaces the value with RedactMask.
+	RedactModeMask RedactMode = iota
+
+	// RedactModeHash replaces the value with its HMAC-SHA256 keyed with
+	// RedactHashKey in the form of hmac-sha256:<hex>. It allows to
+	// correlate entries with the same secret without revealing it. Values
+	// are masked as with RedactModeMask if RedactHashKey is empty since
+	// a plain hash of a short secret is easy to brute-force.
+	RedactModeHash
+)
+
+// String implements fmt.Stringer.
+func (m RedactMode) String() string {
+	switch m {
+	case RedactModeMask:
+		return "mask"
+	case RedactModeHash:
+		return "hash"
+	}
+
+	return "unknown"
+}
+
+// DefaultRedactMask is the default replacement of redacted values.
+const DefaultRedactMask = "[REDACTED]"
+
+// ValueDetector reports whether the given encoded value of a field is
+// sensitive and must be redacted.
+type ValueDetector func(v []byte) bool
+
+// DetectJWT detects JSON Web Tokens optionally prefixed with "Bearer ".
+func DetectJWT(v []byte) bool {
+	if len(v) > 7 && string(v[:7]) == "Bearer " {
+		v = v[7:]
+	}
+	// The header is a base64url encoded JSON object. It starts with {".
+	if len(v) < 3 || string(v[:3]) != "eyJ" {
+		return false
+	}
+
+	dots := 0
+	for i, c := range v {
+		switch {
+		case c == '.':
+			// Only the signature could be empty.
+			if i == 0 || v[i-1] == '.' {
+				return false
+			}
+			dots++
+		case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '=':
+		default:
+			return false
+		}
+	}
+
+	return dots == 2
+}
+
+// DetectCreditCard detects payment card numbers: 13 to 19 digits
+// optionally separated by spaces or dashes that pass the Luhn check.
+func DetectCreditCard(v []byte) bool {
+	digits := 0
+	sum := 0
+	for i := len(v) - 1; i >= 0; i-- {
+		c := v[i]
+		switch {
+		case c >= '0' && c <= '9':
+			d := int(c - '0')
+			if digits%2 == 1 {
+				d *= 2
+				if d > 9 {
+					d -= 9
+				}
+			}
+			sum += d
+			digits++
+		case c == ' ' || c == '-':
+			if i == 0 || i == len(v)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
+
+// matchKeyPattern reports whether the given key matches any of glob
+// patterns or regular expressions. Malformed patterns match nothing.
+func matchKeyPattern(k string, globs []string, regexps []*regexp.Regexp) bool {
+	for _, p := range globs {
+		if ok, _ := path.Match(p, k); ok {
+			return true
+		}
+	}
+	for _, re := range regexps {
+		if re.MatchString(k) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package logfjournald
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmsghdr mirrors struct mmsghdr from <sys/socket.h>.
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// mmsgScratch holds buffers reused between sendmmsg calls to keep the
+// batched write path allocation free.
+type mmsgScratch struct {
+	iovs []unix.Iovec
+	hdrs []mmsghdr
+}
+
+// sendmmsg sends each of the given messages as a separate datagram to
+// the given address with a single sendmmsg(2) call. It returns the
+// number of messages sent.
+func sendmmsg(c *net.UnixConn, addr *net.UnixAddr, msgs [][]byte, s *mmsgScratch) (int, error) {
+	var sa unix.RawSockaddrUnix
+	sa.Family = unix.AF_UNIX
+	if len(addr.Name) >= len(sa.Path) {
+		return 0, syscall.EINVAL
+	}
+	for i := 0; i < len(addr.Name); i++ {
+		sa.Path[i] = int8(addr.Name[i])
+	}
+	// Family, path and a terminating NUL.
+	salen := uint32(2 + len(addr.Name) + 1)
+
+	if cap(s.hdrs) < len(msgs) {
+		s.iovs = make([]unix.Iovec, len(msgs))
+		s.hdrs = make([]mmsghdr, len(msgs))
+	}
+	iovs := s.iovs[:len(msgs)]
+	hdrs := s.hdrs[:len(msgs)]
+	for i, msg := range msgs {
+		iovs[i] = unix.Iovec{}
+		if len(msg) != 0 {
+			iovs[i].Base = &msg[0]
+		}
+		iovs[i].SetLen(len(msg))
+
+		hdrs[i] = mmsghdr{}
+		hdrs[i].hdr.Name = (*byte)(unsafe.Pointer(&sa))
+		hdrs[i].hdr.Namelen = salen
+		hdrs[i].hdr.Iov = &iovs[i]
+		hdrs[i].hdr.SetIovlen(1)
+	}
+
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var n uintptr
+	var errno syscall.Errno
+	err = rawConn.Write(func(fd uintptr) bool {
+		n, _, errno = syscall.Syscall6(unix.SYS_SENDMMSG, fd, uintptr(unsafe.Pointer(&hdrs[0])), uintptr(len(hdrs)), 0, 0, 0)
+		// Wait for the socket to become writable.
+		return errno != syscall.EAGAIN
+	})
+	runtime.KeepAlive(&sa)
+	runtime.KeepAlive(msgs)
+
+	// Release references to messages.
+	for i := range iovs {
+		iovs[i].Base = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, os.NewSyscallError("sendmmsg", errno)
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package logfjournald
+
+import (
+	"net"
+	"syscall"
+)
+
+// mmsgScratch is not used on systems without sendmmsg(2).
+type mmsgScratch struct{}
+
+// sendmmsg is not available on this system. The caller falls back to
+// writing messages one by one.
+func sendmmsg(c *net.UnixConn, addr *net.UnixAddr, msgs [][]byte, s *mmsgScratch) (int, error) {
+	return 0, syscall.ENOSYS
+}
@@ -0,0 +1,33 @@
+package logfjournald
+
+// Facility is the syslog facility code written as the native journal's
+// SYSLOG_FACILITY field.
+type Facility int
+
+// Syslog facility codes. See syslog(3).
+//
+// FacilityKern is reserved for kernel messages and could not be used by
+// user processes, so EncoderConfig treats it as unset.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+
+	FacilityLocal0 Facility = iota + 4
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)